@@ -39,6 +39,9 @@ type BusDataClient struct {
 	username   string
 	password   string
 	busDataURL string
+	httpClient httpClient
+	instrumentation
+	observer StreamObserver
 
 	// Map from VehicleID to BusVehicleData checksum
 	// used in GetBusVehicleDataStream to dedupe messages
@@ -51,9 +54,24 @@ func NewBusDataClient(username, password, busDataURL string) *BusDataClient {
 		username:   username,
 		password:   password,
 		busDataURL: busDataURL,
+		httpClient: http.DefaultClient,
+		instrumentation: instrumentation{
+			client: "BusDataClient",
+		},
 	}
 }
 
+// StreamObserver is notified about each poll of GetBusVehicleDataStream
+// with number of received and emitted rows, e.g. to collect metrics
+type StreamObserver interface {
+	ObservePoll(name string, rows, emitted int, err error)
+}
+
+// SetStreamObserver sets observer of GetBusVehicleDataStream polls, nil disables it
+func (c *BusDataClient) SetStreamObserver(observer StreamObserver) {
+	c.observer = observer
+}
+
 // GetBusVehicleData - Status By Bus data
 // This Method will provide Bus Vehicle Information.
 // It will list the vehicles currently reporting real-time information.
@@ -62,7 +80,7 @@ func (c *BusDataClient) GetBusVehicleData() (*GetBusVehicleDataResponse, error)
 	v.Add("username", c.username)
 	v.Add("password", c.password)
 
-	resp, err := http.PostForm(fmt.Sprintf("%s/getBusVehicleDataXML", c.busDataURL), v)
+	resp, err := postForm(c.httpClient, &c.instrumentation, fmt.Sprintf("%s/getBusVehicleDataXML", c.busDataURL), v)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send GetBusVehicleData request: %v", err)
 	}
@@ -90,15 +108,23 @@ func (c *BusDataClient) GetBusVehicleDataStream(r chan BusVehicleDataRow, e chan
 	for {
 		resp, err := c.GetBusVehicleData()
 		if err != nil {
+			if c.observer != nil {
+				c.observer.ObservePoll("GetBusVehicleDataStream", 0, 0, err)
+			}
 			e <- err
 			continue
 		}
 
+		emitted := 0
 		for _, row := range resp.Rows {
 			if !dedupe || c.isUniqueBusVehicleDataRow(row) {
 				r <- row
+				emitted++
 			}
 		}
+		if c.observer != nil {
+			c.observer.ObservePoll("GetBusVehicleDataStream", len(resp.Rows), emitted, nil)
+		}
 		time.Sleep(updateTnterval)
 	}
 }
@@ -112,7 +138,7 @@ func (c *BusDataClient) GetNextTrips(request GetNextTripsRequest) (*GetNextTrips
 	v.Add("password", c.password)
 	v.Add("stopid", fmt.Sprintf("%d", request.StopID))
 
-	resp, err := http.PostForm(fmt.Sprintf("%s/getNextTripsXML", c.busDataURL), v)
+	resp, err := postForm(c.httpClient, &c.instrumentation, fmt.Sprintf("%s/getNextTripsXML", c.busDataURL), v)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send GetNextTrips request: %v", err)
 	}
@@ -142,7 +168,7 @@ func (c *BusDataClient) GetBusDV(request GetBusDVRequest) (*GetBusDVResponse, er
 	v.Add("password", c.password)
 	v.Add("location", request.Location)
 
-	resp, err := http.PostForm(fmt.Sprintf("%s/getBusDVXML", c.busDataURL), v)
+	resp, err := postForm(c.httpClient, &c.instrumentation, fmt.Sprintf("%s/getBusDVXML", c.busDataURL), v)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send GetBusDV request: %v", err)
 	}
@@ -169,7 +195,7 @@ func (c *BusDataClient) GetBusLocations() (*GetBusLocationsResponse, error) {
 	v.Add("username", c.username)
 	v.Add("password", c.password)
 
-	resp, err := http.PostForm(fmt.Sprintf("%s/getBusLocationsXML", c.busDataURL), v)
+	resp, err := postForm(c.httpClient, &c.instrumentation, fmt.Sprintf("%s/getBusLocationsXML", c.busDataURL), v)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send GetBusLocations request: %v", err)
 	}
@@ -197,7 +223,7 @@ func (c *BusDataClient) GetMessages(request GetMessagesRequest) (*GetMessagesRes
 	v.Add("password", c.password)
 	v.Add("stopid", fmt.Sprintf("%d", request.StopID))
 
-	resp, err := http.PostForm(fmt.Sprintf("%s/getMessagesXML", c.busDataURL), v)
+	resp, err := postForm(c.httpClient, &c.instrumentation, fmt.Sprintf("%s/getMessagesXML", c.busDataURL), v)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send GetMessages request: %v", err)
 	}
@@ -228,7 +254,7 @@ func (c *BusDataClient) GetScheduleData(request GetScheduleDataRequest) (*GetSch
 	v.Add("site", request.Site)
 	v.Add("minutes", fmt.Sprintf("%d", request.Minutes))
 
-	resp, err := http.PostForm(fmt.Sprintf("%s/getScheduleDataXML", c.busDataURL), v)
+	resp, err := postForm(c.httpClient, &c.instrumentation, fmt.Sprintf("%s/getScheduleDataXML", c.busDataURL), v)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send GetScheduleData request: %v", err)
 	}
@@ -258,7 +284,7 @@ func (c *BusDataClient) GetScheduleXGTFS(request GetScheduleXGTFSRequest) (*GetS
 	v.Add("site", request.Site)
 	v.Add("minutes", fmt.Sprintf("%d", request.Minutes))
 
-	resp, err := http.PostForm(fmt.Sprintf("%s/getScheduleXGTFS", c.busDataURL), v)
+	resp, err := postForm(c.httpClient, &c.instrumentation, fmt.Sprintf("%s/getScheduleXGTFS", c.busDataURL), v)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send GetScheduleXGTFS request: %v", err)
 	}
@@ -0,0 +1,68 @@
+// Command gtfsdiff compares two GTFS static archives (as returned by BusClient.GetGTFS)
+// and reports added, removed and renamed stops, routes, trips and shapes,
+// changed service calendars and moved stops.
+//
+// Usage:
+//
+//	gtfsdiff [-json] [-threshold meters] old.zip new.zip
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/errornil/njtransit/v2/gtfsdiff"
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print diff as JSON")
+	threshold := flag.Float64("threshold", gtfsdiff.DefaultMoveThresholdMeters, "report stops moved more than this distance, in meters")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-json] [-threshold meters] old.zip new.zip\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	oldFeed, err := parseFile(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to parse %s: %v", flag.Arg(0), err)
+	}
+	newFeed, err := parseFile(flag.Arg(1))
+	if err != nil {
+		log.Fatalf("Failed to parse %s: %v", flag.Arg(1), err)
+	}
+
+	diff := gtfsdiff.Compare(oldFeed, newFeed, *threshold)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(diff)
+	} else {
+		err = diff.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write diff: %v", err)
+	}
+
+	if !diff.Empty() {
+		os.Exit(1)
+	}
+}
+
+func parseFile(name string) (*gtfsstatic.Feed, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return gtfsstatic.Parse(b)
+}
@@ -0,0 +1,186 @@
+// Command gtfsrt-server polls NJ TRANSIT once and republishes real-time data
+// as standard GTFS-Realtime endpoints, so many internal consumers share one API quota:
+//
+//	/tripupdates.pb, /vehiclepositions.pb, /alerts.pb - bus, from BusClient
+//	/rail/tripupdates.pb, /rail/vehiclepositions.pb, /rail/alerts.pb - rail, from TrainDataClient
+//
+// Add ?format=json to any endpoint for a JSON debug view.
+// /health returns freshness status of each feed, stale vehicle positions are dropped.
+// /metrics returns NJ TRANSIT API usage metrics in Prometheus text format.
+// Credentials are read from BUS_USERNAME, BUS_PASSWORD and USER_AGENT environment variables,
+// and TRAINDATA_USERNAME, TRAINDATA_PASSWORD for rail (enabled with -rail-stations).
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+	"github.com/errornil/njtransit/v2/gtfsrt"
+	"github.com/errornil/njtransit/v2/metrics"
+	gtfs "github.com/errornil/transit_realtime"
+)
+
+type feed struct {
+	name    string
+	fetch   func() (*gtfs.FeedMessage, error)
+	handler *gtfsrt.FeedHandler
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	interval := flag.Duration("interval", 30*time.Second, "how often to poll NJ TRANSIT")
+	busURL := flag.String("bus-url", njt.BusProdURL, "BusClient API URL")
+	railStations := flag.String("rail-stations", "", "comma-separated two-character station codes to poll for rail feeds, rail is disabled if empty")
+	trainDataURL := flag.String("traindata-url", njtv1.TrainDataProdURL, "TrainDataClient API URL")
+	flag.Parse()
+
+	hc := &http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	collector := metrics.NewCollector()
+
+	busClient, err := njt.NewBusClient(
+		*busURL,
+		os.Getenv("BUS_USERNAME"),
+		os.Getenv("BUS_PASSWORD"),
+		os.Getenv("USER_AGENT"),
+		hc,
+	)
+	if err != nil {
+		log.Fatalf("Failed to create BusClient: %v", err)
+	}
+	busClient.SetRoundTripObserver(collector)
+
+	feeds := []*feed{
+		{name: "/tripupdates.pb", fetch: busClient.GetTripUpdates},
+		{name: "/vehiclepositions.pb", fetch: busClient.GetVehiclePositions},
+		{name: "/alerts.pb", fetch: busClient.GetAlerts},
+	}
+
+	var rail *railPoller
+	if stations := splitList(*railStations); len(stations) > 0 {
+		rail = &railPoller{
+			client: njtv1.NewTrainDataClient(
+				hc,
+				os.Getenv("TRAINDATA_USERNAME"),
+				os.Getenv("TRAINDATA_PASSWORD"),
+				*trainDataURL,
+			),
+			converter: gtfsrt.NewRailConverter(nil, nil, nil),
+			stations:  stations,
+		}
+		rail.client.SetRoundTripObserver(collector)
+		feeds = append(feeds,
+			&feed{name: "/rail/tripupdates.pb", fetch: rail.tripUpdates},
+			&feed{name: "/rail/vehiclepositions.pb", fetch: rail.vehiclePositions},
+			&feed{name: "/rail/alerts.pb", fetch: rail.alerts},
+		)
+	}
+
+	monitor := gtfsrt.NewMonitor(gtfsrt.DefaultThresholds)
+
+	mux := http.NewServeMux()
+	for _, f := range feeds {
+		f.handler = gtfsrt.NewFeedHandler(*interval)
+		mux.Handle(f.name, f.handler)
+	}
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(monitor.Health())
+	})
+	mux.Handle("/metrics", collector)
+
+	go func() {
+		for {
+			if rail != nil {
+				rail.poll()
+			}
+			for _, f := range feeds {
+				message, err := f.fetch()
+				if err != nil {
+					collector.ObservePoll(f.name, 0, 0, err)
+					log.Printf("Failed to fetch %s: %v", f.name, err)
+					continue
+				}
+				entities := len(message.GetEntity())
+
+				now := time.Now()
+				freshness := monitor.Observe(f.name, gtfsrt.CheckFeed(message, now, gtfsrt.DefaultThresholds), now)
+				if freshness.Health != gtfsrt.HealthOK {
+					log.Printf("%s is %s: feed age %s, %d stale and %d future entities", f.name, freshness.Health, freshness.FeedAge, freshness.StaleEntities, freshness.FutureEntities)
+				}
+				message = gtfsrt.FilterStaleVehicles(message, now, gtfsrt.DefaultThresholds)
+				collector.ObservePoll(f.name, entities, len(message.GetEntity()), nil)
+
+				if err = f.handler.Update(message); err != nil {
+					log.Printf("Failed to update %s: %v", f.name, err)
+				}
+			}
+			time.Sleep(*interval)
+		}
+	}()
+
+	log.Printf("Listening on %s...", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// railPoller polls TrainDataClient for each station once per round
+type railPoller struct {
+	client    *njtv1.TrainDataClient
+	converter *gtfsrt.RailConverter
+	stations  []string
+	polledAt  time.Time
+}
+
+func (p *railPoller) poll() {
+	p.converter.Reset()
+	for _, station := range p.stations {
+		station = strings.TrimSpace(station)
+
+		schedule, err := p.client.GetTrainSchedule19Rec(station)
+		if err != nil {
+			log.Printf("Failed to call GetTrainSchedule19Rec for %s: %v", station, err)
+		} else {
+			p.converter.AddTrainSchedule19Rec(schedule)
+		}
+
+		message, err := p.client.GetStationMessage(station, "")
+		if err != nil {
+			log.Printf("Failed to call GetStationMessage for %s: %v", station, err)
+		} else {
+			p.converter.AddStationMessage(message)
+		}
+	}
+	p.polledAt = time.Now()
+}
+
+func (p *railPoller) tripUpdates() (*gtfs.FeedMessage, error) {
+	return p.converter.TripUpdates(p.polledAt), nil
+}
+
+func (p *railPoller) vehiclePositions() (*gtfs.FeedMessage, error) {
+	return p.converter.VehiclePositions(p.polledAt), nil
+}
+
+func (p *railPoller) alerts() (*gtfs.FeedMessage, error) {
+	return p.converter.Alerts(p.polledAt), nil
+}
+
+// splitList splits comma-separated list, trims entries and skips empty ones
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -29,15 +29,15 @@ func main() {
 		log.Fatalf("Failed to create BusClient: %v", err)
 	}
 
-	// dv, err := client.GetBusDV("31198", )
-	dv, err := client.GetVehicleLocations(
-		"40.737169",
-		"-74.169868",
-		2000,
-		"ALL",
-	)
+	// dv, err := client.GetBusDVWithRequest(njt.GetBusDVRequest{Stop: "31198"})
+	dv, err := client.GetVehicleLocationsWithRequest(njt.GetVehicleLocationsRequest{
+		Lat:    40.737169,
+		Lon:    -74.169868,
+		Radius: 2000 * njt.Foot,
+		Mode:   njt.ModeAll,
+	})
 	if err != nil {
-		log.Fatalf("Failed to call GetVehicleLocations: %v", err)
+		log.Fatalf("Failed to call GetVehicleLocationsWithRequest: %v", err)
 	}
 
 	b, err := json.MarshalIndent(dv, "", "  ")
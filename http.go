@@ -1,8 +1,13 @@
 package njtransit
 
 import (
+	"bytes"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"path"
+	"time"
 )
 
 type httpClient interface {
@@ -10,3 +15,63 @@ type httpClient interface {
 	// Post(url, contentType string, body io.Reader) (resp *Response, err error)
 	PostForm(url string, data url.Values) (resp *http.Response, err error)
 }
+
+// postForm calls client.PostForm through middlewares and observer of in.
+// Requests sent to API are logged at debug level if logger is set.
+func postForm(client httpClient, in *instrumentation, url string, data url.Values) (*http.Response, error) {
+	send := func(call Call) (*Response, error) {
+		start := time.Now()
+		resp, err := client.PostForm(url, data)
+		if err != nil {
+			in.observe(call, 0, time.Since(start), err)
+			logRequest(in.logger, call.Endpoint, 0, time.Since(start), 0, err)
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		in.observe(call, resp.StatusCode, time.Since(start), err)
+		logRequest(in.logger, call.Endpoint, resp.StatusCode, time.Since(start), len(body), err)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+	}
+
+	resp, err := Chain(in.middlewares, send)(NewCall(in.client, path.Base(url), data))
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       io.NopCloser(bytes.NewReader(resp.Body)),
+	}, nil
+}
+
+func (in *instrumentation) observe(call Call, status int, duration time.Duration, err error) {
+	if in.observer != nil {
+		in.observer.ObserveRoundTrip(call, status, duration, err)
+	}
+}
+
+// logRequest logs a request with the same message and attributes as v2 clients,
+// legacy clients never retry so retries is always 0
+func logRequest(logger *slog.Logger, endpoint string, status int, latency time.Duration, size int, err error) {
+	if logger == nil {
+		return
+	}
+
+	attrs := []any{
+		"endpoint", endpoint,
+		"status", status,
+		"latency", latency,
+		"bytes", size,
+		"retries", 0,
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	logger.Debug("njtransit request", attrs...)
+}
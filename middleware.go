@@ -0,0 +1,112 @@
+package njtransit
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Call is an API call passed through Middleware
+type Call struct {
+	Client   string     // BusDataClient, TrainDataClient, BusClient or BusDV2Client
+	Endpoint string     // API method, e.g. getBusVehicleDataXML
+	Params   url.Values // request parameters without credentials and token
+	Retries  int        // number of previous attempts of this request, e.g. 1 after token refresh
+}
+
+// Response is a raw API response
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Handler performs Call
+type Handler func(call Call) (*Response, error)
+
+// Middleware wraps Handler to collect metrics, trace, cache or audit API calls.
+// Middleware may return Response without calling next, e.g. from cache.
+type Middleware func(next Handler) Handler
+
+// RoundTripObserver is notified about every HTTP request sent to API, including
+// logins and retries. It runs inside middlewares, so calls answered by a middleware
+// without sending a request (e.g. from cache) are not observed.
+type RoundTripObserver interface {
+	ObserveRoundTrip(call Call, status int, duration time.Duration, err error)
+}
+
+// Hooks is Middleware built from before-request and after-response callbacks, both are optional
+type Hooks struct {
+	BeforeRequest func(call Call)
+	AfterResponse func(call Call, status int, duration time.Duration, err error)
+}
+
+// Middleware returns Middleware calling hooks around each API call
+func (h Hooks) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(call Call) (*Response, error) {
+			if h.BeforeRequest != nil {
+				h.BeforeRequest(call)
+			}
+
+			start := time.Now()
+			resp, err := next(call)
+
+			if h.AfterResponse != nil {
+				status := 0
+				if resp != nil {
+					status = resp.StatusCode
+				}
+				h.AfterResponse(call, status, time.Since(start), err)
+			}
+			return resp, err
+		}
+	}
+}
+
+// Chain wraps handler with middlewares, the first middleware is the outermost
+func Chain(middlewares []Middleware, handler Handler) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// instrumentation holds logger, middlewares and observer of a legacy client
+type instrumentation struct {
+	client      string // client name for Call.Client
+	logger      *slog.Logger
+	middlewares []Middleware
+	observer    RoundTripObserver
+}
+
+// SetLogger sets logger for request events at debug level, nil disables logging
+func (in *instrumentation) SetLogger(logger *slog.Logger) {
+	in.logger = logger
+}
+
+// Use adds middlewares wrapping every API call, the first added is the outermost
+func (in *instrumentation) Use(middlewares ...Middleware) {
+	in.middlewares = append(in.middlewares, middlewares...)
+}
+
+// SetRoundTripObserver sets observer of HTTP requests sent to API, nil disables it
+func (in *instrumentation) SetRoundTripObserver(observer RoundTripObserver) {
+	in.observer = observer
+}
+
+// credentialParams are removed from Call.Params
+var credentialParams = []string{"username", "password", "token"}
+
+// NewCall creates Call with credentials removed from params
+func NewCall(client, endpoint string, params url.Values) Call {
+	clean := url.Values{}
+	for k, v := range params {
+		clean[k] = append([]string(nil), v...)
+	}
+	for _, k := range credentialParams {
+		clean.Del(k)
+	}
+	return Call{Client: client, Endpoint: endpoint, Params: clean}
+}
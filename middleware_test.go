@@ -0,0 +1,115 @@
+package njtransit
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMiddleware(t *testing.T) {
+	httpClient := new(httpClientMock)
+	httpClient.
+		On("PostForm", "https://example.com/NJTBusData.asmx/getNextTripsXML", mock.Anything).
+		Return(&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       &closingBuffer{bytes.NewBufferString(`<nextTrips></nextTrips>`)},
+		}, nil).
+		Once()
+
+	client := NewBusDataClient("user", "pass", "https://example.com/NJTBusData.asmx")
+	client.httpClient = httpClient
+
+	var order []string
+	var calls []Call
+	var statuses []int
+	client.Use(
+		Hooks{
+			BeforeRequest: func(call Call) {
+				order = append(order, "before")
+				calls = append(calls, call)
+			},
+			AfterResponse: func(call Call, status int, duration time.Duration, err error) {
+				order = append(order, "after")
+				statuses = append(statuses, status)
+			},
+		}.Middleware(),
+		cacheMiddleware(),
+	)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.GetNextTrips(GetNextTripsRequest{StopID: 19159})
+		assert.NoError(t, err)
+	}
+
+	httpClient.AssertExpectations(t)
+	assert.Equal(t, []string{"before", "after", "before", "after"}, order)
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK}, statuses)
+	assert.Equal(t, Call{
+		Client:   "BusDataClient",
+		Endpoint: "getNextTripsXML",
+		Params:   url.Values{"stopid": {"19159"}},
+	}, calls[0])
+}
+
+func TestRequestLogger(t *testing.T) {
+	httpClient := new(httpClientMock)
+	httpClient.
+		On("PostForm", "https://example.com/NJTBusData.asmx/getNextTripsXML", mock.Anything).
+		Return(&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       &closingBuffer{bytes.NewBufferString(`<nextTrips></nextTrips>`)},
+		}, nil).
+		Once()
+	httpClient.
+		On("PostForm", "https://example.com/NJTBusData.asmx/getBusLocationsXML", mock.Anything).
+		Return((*http.Response)(nil), errors.New("timeout")).
+		Once()
+
+	client := NewBusDataClient("user", "pass", "https://example.com/NJTBusData.asmx")
+	client.httpClient = httpClient
+
+	logs := &bytes.Buffer{}
+	client.SetLogger(slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	_, err := client.GetNextTrips(GetNextTripsRequest{StopID: 19159})
+	assert.NoError(t, err)
+	_, err = client.GetBusLocations()
+	assert.Error(t, err)
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Contains(t, lines[0], `msg="njtransit request" endpoint=getNextTripsXML status=200`)
+		assert.Contains(t, lines[0], "bytes=23 retries=0")
+		assert.NotContains(t, lines[0], "error=")
+		assert.Contains(t, lines[1], `msg="njtransit request" endpoint=getBusLocationsXML status=0`)
+		assert.Contains(t, lines[1], "error=timeout")
+	}
+	assert.NotContains(t, logs.String(), "user")
+	assert.NotContains(t, logs.String(), "pass")
+}
+
+// cacheMiddleware returns responses of repeated calls without calling API
+func cacheMiddleware() Middleware {
+	cache := map[string]*Response{}
+	return func(next Handler) Handler {
+		return func(call Call) (*Response, error) {
+			key := call.Endpoint + "?" + call.Params.Encode()
+			if resp, ok := cache[key]; ok {
+				return resp, nil
+			}
+			resp, err := next(call)
+			if err == nil {
+				cache[key] = resp
+			}
+			return resp, err
+		}
+	}
+}
@@ -24,6 +24,7 @@ type TrainDataClient struct {
 	password     string
 	trainDataURL string
 	replacer     *strings.Replacer
+	instrumentation
 }
 
 func NewTrainDataClient(httpClient httpClient, username, password, trainDataURL string) *TrainDataClient {
@@ -36,6 +37,9 @@ func NewTrainDataClient(httpClient httpClient, username, password, trainDataURL
 			" -SEC", "",
 			"-BH", "",
 		),
+		instrumentation: instrumentation{
+			client: "TrainDataClient",
+		},
 	}
 }
 
@@ -45,7 +49,7 @@ func (t *TrainDataClient) GetStationList() (*GetStationListResponse, error) {
 	v.Add("username", t.username)
 	v.Add("password", t.password)
 
-	resp, err := t.httpClient.PostForm(fmt.Sprintf("%s/getStationListXML", t.trainDataURL), v)
+	resp, err := postForm(t.httpClient, &t.instrumentation, fmt.Sprintf("%s/getStationListXML", t.trainDataURL), v)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to send GetStationList request")
 	}
@@ -82,7 +86,7 @@ func (t *TrainDataClient) GetStationSchedule(station string, njtransitOnly bool)
 	v.Add("station", station)
 	v.Add("NJT_Only", njtransitOnlyValue)
 
-	resp, err := t.httpClient.PostForm(fmt.Sprintf("%s/getStationScheduleXML", t.trainDataURL), v)
+	resp, err := postForm(t.httpClient, &t.instrumentation, fmt.Sprintf("%s/getStationScheduleXML", t.trainDataURL), v)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to send GetStationSchedule request")
 	}
@@ -111,7 +115,7 @@ func (t *TrainDataClient) GetStationMessage(station, trainLine string) (*GetStat
 	v.Add("station", station)
 	v.Add("trainLine", trainLine)
 
-	resp, err := t.httpClient.PostForm(fmt.Sprintf("%s/getStationMSGXML", t.trainDataURL), v)
+	resp, err := postForm(t.httpClient, &t.instrumentation, fmt.Sprintf("%s/getStationMSGXML", t.trainDataURL), v)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to send GetStationMessage request")
 	}
@@ -151,7 +155,7 @@ func (t *TrainDataClient) GetTrainSchedule19Rec(station string) (*GetTrainSchedu
 	v.Add("password", t.password)
 	v.Add("station", station)
 
-	resp, err := t.httpClient.PostForm(fmt.Sprintf("%s/getTrainScheduleXML19Rec", t.trainDataURL), v)
+	resp, err := postForm(t.httpClient, &t.instrumentation, fmt.Sprintf("%s/getTrainScheduleXML19Rec", t.trainDataURL), v)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to send GetTrainSchedule19Rec request")
 	}
@@ -79,7 +79,7 @@ type GetTrainSchedule19RecResponseItem struct {
 	TrainID           string `xml:"TRAIN_ID"`
 	ConnectingTrainID string `xml:"CONNECTING_TRAIN_ID"`
 	Status            string `xml:"STATUS"`
-	SecLate           int    `xml:"SEC_LATE"`
+	SecLate           string `xml:"SEC_LATE"`
 	LastModified      string `xml:"LAST_MODIFIED"`
 	BackgroundColor   string `xml:"BACKCOLOR"`
 	ForegroundColor   string `xml:"FORECOLOR"`
@@ -347,7 +347,7 @@ func TestTrainClientGetTrainSchedule19Rec(t *testing.T) {
 					TrainID:           "7285",
 					ConnectingTrainID: "4785",
 					Status:            "in 24 Min",
-					SecLate:           534,
+					SecLate:           "534",
 					LastModified:      "12-Oct-2019 11:29:46 PM",
 					BackgroundColor:   "CornflowerBlue",
 					ForegroundColor:   "white",
@@ -0,0 +1,176 @@
+package alerts
+
+import (
+	"strings"
+	"time"
+
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	gtfs "github.com/errornil/transit_realtime"
+)
+
+// Translate picks the best translation for the language:
+// exact match ("es-US"), then base language ("es"), then fallback languages
+// in the given order, then translation without language, then the first one.
+// Language tags are compared case-insensitively.
+func Translate(s *gtfs.TranslatedString, language string, fallback ...string) string {
+	translations := s.GetTranslation()
+	if len(translations) == 0 {
+		return ""
+	}
+
+	find := func(match func(lang string) bool) (string, bool) {
+		for _, t := range translations {
+			if match(strings.ToLower(t.GetLanguage())) {
+				return t.GetText(), true
+			}
+		}
+		return "", false
+	}
+
+	for _, lang := range append([]string{language}, fallback...) {
+		lang = strings.ToLower(lang)
+		if lang == "" {
+			continue
+		}
+		if text, ok := find(func(l string) bool { return l == lang }); ok {
+			return text
+		}
+		base := baseLanguage(lang)
+		if text, ok := find(func(l string) bool { return l != "" && baseLanguage(l) == base }); ok {
+			return text
+		}
+	}
+
+	if text, ok := find(func(l string) bool { return l == "" }); ok {
+		return text
+	}
+	return translations[0].GetText()
+}
+
+// EntityContext is an informed entity with names from GTFS static feed,
+// names are empty if the entity is not in the feed
+type EntityContext struct {
+	AgencyID       string
+	RouteID        string
+	RouteShortName string
+	RouteLongName  string
+	RouteType      *int32
+	DirectionID    *uint32
+	TripID         string
+	TripHeadsign   string
+	StopID         string
+	StopCode       string
+	StopName       string
+}
+
+// Period is an alert active period, zero Start or End means open-ended
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// AlertContext is an alert ready to render: translated texts and resolved informed entities
+type AlertContext struct {
+	Header        string
+	Description   string
+	URL           string
+	Cause         gtfs.Alert_Cause
+	Effect        gtfs.Alert_Effect
+	Severity      gtfs.Alert_SeverityLevel
+	ActivePeriods []Period
+	Entities      []EntityContext
+}
+
+// Resolver enriches alerts with route, stop and trip names from GTFS static feed
+type Resolver struct {
+	routes map[string]*gtfsstatic.Route
+	stops  map[string]*gtfsstatic.Stop
+	trips  map[string]*gtfsstatic.Trip
+}
+
+// NewResolver creates new Resolver, feed can be nil to only translate texts
+func NewResolver(feed *gtfsstatic.Feed) *Resolver {
+	if feed == nil {
+		return &Resolver{}
+	}
+	return &Resolver{
+		routes: feed.RouteByID(),
+		stops:  feed.StopByID(),
+		trips:  feed.TripByID(),
+	}
+}
+
+// Resolve translates alert texts and resolves its informed entities
+func (r *Resolver) Resolve(alert *gtfs.Alert, language string, fallback ...string) AlertContext {
+	ctx := AlertContext{
+		Header:      Translate(alert.GetHeaderText(), language, fallback...),
+		Description: Translate(alert.GetDescriptionText(), language, fallback...),
+		URL:         Translate(alert.GetUrl(), language, fallback...),
+		Cause:       alert.GetCause(),
+		Effect:      alert.GetEffect(),
+		Severity:    alert.GetSeverityLevel(),
+	}
+
+	for _, p := range alert.GetActivePeriod() {
+		period := Period{}
+		if p.GetStart() != 0 {
+			period.Start = time.Unix(int64(p.GetStart()), 0)
+		}
+		if p.GetEnd() != 0 {
+			period.End = time.Unix(int64(p.GetEnd()), 0)
+		}
+		ctx.ActivePeriods = append(ctx.ActivePeriods, period)
+	}
+
+	for _, selector := range alert.GetInformedEntity() {
+		ctx.Entities = append(ctx.Entities, r.Entity(selector))
+	}
+	return ctx
+}
+
+// Entity resolves informed entity selector, route is taken from the trip if selector has none
+func (r *Resolver) Entity(selector *gtfs.EntitySelector) EntityContext {
+	e := EntityContext{
+		AgencyID: selector.GetAgencyId(),
+		RouteID:  selector.GetRouteId(),
+		TripID:   selector.GetTrip().GetTripId(),
+		StopID:   selector.GetStopId(),
+	}
+	if selector.RouteType != nil {
+		e.RouteType = selector.RouteType
+	}
+	if selector.DirectionId != nil {
+		e.DirectionID = selector.DirectionId
+	} else if trip := selector.GetTrip(); trip != nil && trip.DirectionId != nil {
+		e.DirectionID = trip.DirectionId
+	}
+	if e.RouteID == "" {
+		e.RouteID = selector.GetTrip().GetRouteId()
+	}
+
+	if trip, ok := r.trips[e.TripID]; ok {
+		e.TripHeadsign = trip.Headsign
+		if e.RouteID == "" {
+			e.RouteID = trip.RouteID
+		}
+	}
+	if route, ok := r.routes[e.RouteID]; ok {
+		e.RouteShortName = route.ShortName
+		e.RouteLongName = route.LongName
+		if e.AgencyID == "" {
+			e.AgencyID = route.AgencyID
+		}
+	}
+	if stop, ok := r.stops[e.StopID]; ok {
+		e.StopCode = stop.Code
+		e.StopName = stop.Name
+	}
+	return e
+}
+
+func baseLanguage(lang string) string {
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		return lang[:i]
+	}
+	return lang
+}
@@ -0,0 +1,65 @@
+package alerts
+
+import (
+	"testing"
+
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTranslate(t *testing.T) {
+	s := &gtfs.TranslatedString{
+		Translation: []*gtfs.TranslatedString_Translation{
+			{Text: proto.String("Detour"), Language: proto.String("en")},
+			{Text: proto.String("Desvío"), Language: proto.String("es")},
+			{Text: proto.String("Default")},
+		},
+	}
+
+	assert.Equal(t, "Desvío", Translate(s, "es"))
+	assert.Equal(t, "Desvío", Translate(s, "ES-us"))
+	assert.Equal(t, "Detour", Translate(s, "fr", "en"))
+	assert.Equal(t, "Default", Translate(s, "fr"))
+	assert.Equal(t, "", Translate(nil, "en"))
+}
+
+func TestResolve(t *testing.T) {
+	r := NewResolver(&gtfsstatic.Feed{
+		Stops:  []gtfsstatic.Stop{{ID: "100", Code: "20001", Name: "MAIN ST AT 1ST AVE"}},
+		Routes: []gtfsstatic.Route{{ID: "10", AgencyID: "NJB", ShortName: "1", LongName: "Newark - Ivy Hill"}},
+		Trips:  []gtfsstatic.Trip{{ID: "T1", RouteID: "10", Headsign: "1 IVY HILL"}},
+	})
+
+	ctx := r.Resolve(&gtfs.Alert{
+		HeaderText: text("Detour"),
+		InformedEntity: []*gtfs.EntitySelector{
+			{Trip: &gtfs.TripDescriptor{TripId: proto.String("T1")}, StopId: proto.String("100")},
+			{RouteId: proto.String("10")},
+			{StopId: proto.String("100")},
+		},
+	}, "en")
+
+	assert.Equal(t, "Detour", ctx.Header)
+	assert.Equal(t, []EntityContext{{
+		AgencyID:       "NJB",
+		RouteID:        "10",
+		RouteShortName: "1",
+		RouteLongName:  "Newark - Ivy Hill",
+		TripID:         "T1",
+		TripHeadsign:   "1 IVY HILL",
+		StopID:         "100",
+		StopCode:       "20001",
+		StopName:       "MAIN ST AT 1ST AVE",
+	}, {
+		AgencyID:       "NJB",
+		RouteID:        "10",
+		RouteShortName: "1",
+		RouteLongName:  "Newark - Ivy Hill",
+	}, {
+		StopID:   "100",
+		StopCode: "20001",
+		StopName: "MAIN ST AT 1ST AVE",
+	}}, ctx.Entities)
+}
@@ -0,0 +1,340 @@
+package alerts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gtfs "github.com/errornil/transit_realtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultRetention is how long resolved alerts are kept by Tracker
+const DefaultRetention = 24 * time.Hour
+
+// EventType is a type of alert lifecycle Event
+type EventType string
+
+// Event types
+const (
+	Created  EventType = "created"
+	Updated  EventType = "updated"
+	Resolved EventType = "resolved"
+)
+
+// Event is emitted by Tracker when an alert appears, changes or disappears
+type Event struct {
+	Type     EventType
+	AlertID  string
+	Time     time.Time
+	Alert    *gtfs.Alert // current alert, the last known one for Resolved
+	Previous *gtfs.Alert // alert before update, nil for Created and Resolved
+	Changes  []Change    // changed fields for Updated
+}
+
+// Change is a changed alert field with old and new values formatted as text
+type Change struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// TrackedAlert is an alert with its lifecycle timestamps
+type TrackedAlert struct {
+	ID          string
+	Alert       *gtfs.Alert
+	FirstSeen   time.Time
+	LastUpdated time.Time
+	LastSeen    time.Time
+	ResolvedAt  time.Time // zero while alert is in the feed
+}
+
+// Tracker keeps alerts state across polls of BusClient.GetAlerts
+// and emits lifecycle events. Tracker is safe for concurrent use.
+type Tracker struct {
+	retention time.Duration
+
+	mu       sync.RWMutex
+	alerts   map[string]*TrackedAlert
+	handlers []func(Event)
+}
+
+// NewTracker creates new Tracker, resolved alerts are kept for retention
+// (DefaultRetention if not positive)
+func NewTracker(retention time.Duration) *Tracker {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	return &Tracker{
+		retention: retention,
+		alerts:    map[string]*TrackedAlert{},
+	}
+}
+
+// OnEvent registers a handler called for each event emitted by Update
+func (t *Tracker) OnEvent(fn func(Event)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers = append(t.handlers, fn)
+}
+
+// Update applies alerts feed polled at now and returns emitted events.
+// For FULL_DATASET feeds alerts missing from the feed are resolved,
+// for DIFFERENTIAL feeds only entities with is_deleted are.
+func (t *Tracker) Update(feed *gtfs.FeedMessage, now time.Time) []Event {
+	t.mu.Lock()
+
+	var events []Event
+	seen := map[string]bool{}
+	for _, entity := range feed.GetEntity() {
+		id := entity.GetId()
+		current, ok := t.alerts[id]
+		active := ok && current.ResolvedAt.IsZero()
+
+		if entity.GetIsDeleted() {
+			if active {
+				events = append(events, t.resolve(current, now))
+			}
+			continue
+		}
+		alert := entity.GetAlert()
+		if alert == nil {
+			continue
+		}
+		seen[id] = true
+
+		if !active {
+			t.alerts[id] = &TrackedAlert{
+				ID:          id,
+				Alert:       proto.Clone(alert).(*gtfs.Alert),
+				FirstSeen:   now,
+				LastUpdated: now,
+				LastSeen:    now,
+			}
+			events = append(events, Event{Type: Created, AlertID: id, Time: now, Alert: alert})
+			continue
+		}
+
+		current.LastSeen = now
+		if changes := Compare(current.Alert, alert); len(changes) > 0 {
+			events = append(events, Event{
+				Type:     Updated,
+				AlertID:  id,
+				Time:     now,
+				Alert:    alert,
+				Previous: current.Alert,
+				Changes:  changes,
+			})
+			current.Alert = proto.Clone(alert).(*gtfs.Alert)
+			current.LastUpdated = now
+		}
+	}
+
+	if feed.GetHeader().GetIncrementality() == gtfs.FeedHeader_FULL_DATASET {
+		for _, id := range sortedIDs(t.alerts) {
+			if a := t.alerts[id]; a.ResolvedAt.IsZero() && !seen[id] {
+				events = append(events, t.resolve(a, now))
+			}
+		}
+	}
+
+	for id, a := range t.alerts {
+		if !a.ResolvedAt.IsZero() && now.Sub(a.ResolvedAt) > t.retention {
+			delete(t.alerts, id)
+		}
+	}
+
+	handlers := t.handlers
+	t.mu.Unlock()
+
+	for _, event := range events {
+		for _, fn := range handlers {
+			fn(event)
+		}
+	}
+	return events
+}
+
+// Get returns tracked alert by ID, including recently resolved ones
+func (t *Tracker) Get(id string) (TrackedAlert, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	a, ok := t.alerts[id]
+	if !ok {
+		return TrackedAlert{}, false
+	}
+	return *a, true
+}
+
+// All returns all tracked alerts, including recently resolved ones, ordered by ID
+func (t *Tracker) All() []TrackedAlert {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make([]TrackedAlert, 0, len(t.alerts))
+	for _, id := range sortedIDs(t.alerts) {
+		result = append(result, *t.alerts[id])
+	}
+	return result
+}
+
+// Active returns unresolved alerts which are active at the time and affect
+// given route or stop; empty routeID or stopID is not used for matching.
+// Alerts without active periods are considered active until resolved.
+func (t *Tracker) Active(routeID, stopID string, at time.Time) []TrackedAlert {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var result []TrackedAlert
+	for _, id := range sortedIDs(t.alerts) {
+		a := t.alerts[id]
+		if !a.ResolvedAt.IsZero() || !IsActiveAt(a.Alert, at) {
+			continue
+		}
+		for _, selector := range a.Alert.GetInformedEntity() {
+			if Affects(selector, routeID, stopID) {
+				result = append(result, *a)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (t *Tracker) resolve(a *TrackedAlert, now time.Time) Event {
+	a.ResolvedAt = now
+	return Event{Type: Resolved, AlertID: a.ID, Time: now, Alert: a.Alert}
+}
+
+// IsActiveAt returns true if any of alert active periods contains t,
+// or alert has no active periods
+func IsActiveAt(alert *gtfs.Alert, t time.Time) bool {
+	if len(alert.GetActivePeriod()) == 0 {
+		return true
+	}
+
+	ts := uint64(t.Unix())
+	for _, period := range alert.GetActivePeriod() {
+		if (period.GetStart() == 0 || period.GetStart() <= ts) && (period.GetEnd() == 0 || ts < period.GetEnd()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Affects returns true if informed entity selector applies to given route or stop:
+// at least one of them is specified by the selector and none conflicts.
+// Agency-wide selectors affect every route and stop.
+func Affects(selector *gtfs.EntitySelector, routeID, stopID string) bool {
+	selectorRoute := selector.GetRouteId()
+	if selectorRoute == "" {
+		selectorRoute = selector.GetTrip().GetRouteId()
+	}
+	selectorStop := selector.GetStopId()
+
+	if selectorRoute == "" && selectorStop == "" && selector.GetTrip() == nil {
+		return true
+	}
+
+	matched := false
+	if routeID != "" && selectorRoute != "" {
+		if selectorRoute != routeID {
+			return false
+		}
+		matched = true
+	}
+	if stopID != "" && selectorStop != "" {
+		if selectorStop != stopID {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// Compare returns changes between two versions of an alert
+func Compare(old, new *gtfs.Alert) []Change {
+	fields := []struct {
+		name   string
+		format func(*gtfs.Alert) string
+	}{
+		{"header_text", func(a *gtfs.Alert) string { return formatTranslatedString(a.GetHeaderText()) }},
+		{"description_text", func(a *gtfs.Alert) string { return formatTranslatedString(a.GetDescriptionText()) }},
+		{"url", func(a *gtfs.Alert) string { return formatTranslatedString(a.GetUrl()) }},
+		{"active_period", formatActivePeriods},
+		{"informed_entity", formatInformedEntities},
+		{"cause", func(a *gtfs.Alert) string { return a.GetCause().String() }},
+		{"effect", func(a *gtfs.Alert) string { return a.GetEffect().String() }},
+		{"severity_level", func(a *gtfs.Alert) string { return a.GetSeverityLevel().String() }},
+	}
+
+	var changes []Change
+	for _, field := range fields {
+		o, n := field.format(old), field.format(new)
+		if o != n {
+			changes = append(changes, Change{Field: field.name, Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+func formatTranslatedString(s *gtfs.TranslatedString) string {
+	parts := make([]string, 0, len(s.GetTranslation()))
+	for _, t := range s.GetTranslation() {
+		if t.GetLanguage() == "" {
+			parts = append(parts, t.GetText())
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("[%s] %s", t.GetLanguage(), t.GetText()))
+	}
+	return strings.Join(parts, "\n")
+}
+
+func formatActivePeriods(a *gtfs.Alert) string {
+	parts := make([]string, 0, len(a.GetActivePeriod()))
+	for _, p := range a.GetActivePeriod() {
+		parts = append(parts, fmt.Sprintf("%s-%s", formatTimestamp(p.GetStart()), formatTimestamp(p.GetEnd())))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatInformedEntities(a *gtfs.Alert) string {
+	parts := make([]string, 0, len(a.GetInformedEntity()))
+	for _, e := range a.GetInformedEntity() {
+		var fields []string
+		if e.GetAgencyId() != "" {
+			fields = append(fields, "agency="+e.GetAgencyId())
+		}
+		if e.GetRouteId() != "" {
+			fields = append(fields, "route="+e.GetRouteId())
+		}
+		if e.GetTrip().GetTripId() != "" {
+			fields = append(fields, "trip="+e.GetTrip().GetTripId())
+		}
+		if e.GetStopId() != "" {
+			fields = append(fields, "stop="+e.GetStopId())
+		}
+		parts = append(parts, strings.Join(fields, " "))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+func formatTimestamp(ts uint64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(int64(ts), 0).UTC().Format(time.RFC3339)
+}
+
+func sortedIDs(alerts map[string]*TrackedAlert) []string {
+	ids := make([]string, 0, len(alerts))
+	for id := range alerts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,92 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func alertsFeed(alerts map[string]*gtfs.Alert) *gtfs.FeedMessage {
+	feed := &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{GtfsRealtimeVersion: proto.String("2.0")},
+	}
+	for id, alert := range alerts {
+		feed.Entity = append(feed.Entity, &gtfs.FeedEntity{Id: proto.String(id), Alert: alert})
+	}
+	return feed
+}
+
+func text(s string) *gtfs.TranslatedString {
+	return &gtfs.TranslatedString{
+		Translation: []*gtfs.TranslatedString_Translation{{Text: proto.String(s), Language: proto.String("en")}},
+	}
+}
+
+func TestTracker(t *testing.T) {
+	start := time.Date(2024, 9, 30, 8, 0, 0, 0, time.UTC)
+	detour := &gtfs.Alert{
+		HeaderText:     text("Route 1 detoured"),
+		InformedEntity: []*gtfs.EntitySelector{{RouteId: proto.String("1")}},
+		ActivePeriod: []*gtfs.TimeRange{
+			{Start: proto.Uint64(uint64(start.Unix())), End: proto.Uint64(uint64(start.Add(2 * time.Hour).Unix()))},
+		},
+	}
+	stopClosed := &gtfs.Alert{
+		HeaderText:     text("Stop closed"),
+		InformedEntity: []*gtfs.EntitySelector{{StopId: proto.String("20001")}},
+	}
+
+	tracker := NewTracker(0)
+	var handled []EventType
+	tracker.OnEvent(func(e Event) { handled = append(handled, e.Type) })
+
+	events := tracker.Update(alertsFeed(map[string]*gtfs.Alert{"a1": detour, "a2": stopClosed}), start)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, Created, events[0].Type)
+		assert.Equal(t, Created, events[1].Type)
+	}
+
+	// nothing changed
+	assert.Empty(t, tracker.Update(alertsFeed(map[string]*gtfs.Alert{"a1": detour, "a2": stopClosed}), start.Add(time.Minute)))
+
+	updated := proto.Clone(detour).(*gtfs.Alert)
+	updated.HeaderText = text("Route 1 detoured via Broad St")
+	events = tracker.Update(alertsFeed(map[string]*gtfs.Alert{"a1": updated}), start.Add(2*time.Minute))
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, Updated, events[0].Type)
+		assert.Equal(t, []Change{{
+			Field: "header_text",
+			Old:   "[en] Route 1 detoured",
+			New:   "[en] Route 1 detoured via Broad St",
+		}}, events[0].Changes)
+		assert.Equal(t, Resolved, events[1].Type)
+		assert.Equal(t, "a2", events[1].AlertID)
+	}
+	assert.Equal(t, []EventType{Created, Created, Updated, Resolved}, handled)
+
+	a1, ok := tracker.Get("a1")
+	assert.True(t, ok)
+	assert.Equal(t, start, a1.FirstSeen)
+	assert.Equal(t, start.Add(2*time.Minute), a1.LastUpdated)
+
+	a2, ok := tracker.Get("a2")
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(2*time.Minute), a2.ResolvedAt)
+
+	assert.Len(t, tracker.Active("1", "", start.Add(time.Hour)), 1)
+	assert.Len(t, tracker.Active("1", "20001", start.Add(time.Hour)), 1)
+	assert.Empty(t, tracker.Active("2", "", start.Add(time.Hour)))
+	assert.Empty(t, tracker.Active("1", "", start.Add(3*time.Hour)))
+	assert.Empty(t, tracker.Active("", "20001", start.Add(time.Hour)))
+}
+
+func TestAffects(t *testing.T) {
+	assert.True(t, Affects(&gtfs.EntitySelector{AgencyId: proto.String("NJB")}, "1", ""))
+	assert.True(t, Affects(&gtfs.EntitySelector{StopId: proto.String("S")}, "1", "S"))
+	assert.False(t, Affects(&gtfs.EntitySelector{StopId: proto.String("S")}, "1", ""))
+	assert.False(t, Affects(&gtfs.EntitySelector{RouteId: proto.String("2"), StopId: proto.String("S")}, "1", "S"))
+	assert.True(t, Affects(&gtfs.EntitySelector{Trip: &gtfs.TripDescriptor{RouteId: proto.String("1")}}, "1", ""))
+}
@@ -1,14 +1,8 @@
 package njtransit
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
-	"mime/multipart"
 	"net/http"
-	"net/url"
-	"strings"
 
 	gtfs "github.com/errornil/transit_realtime"
 	"google.golang.org/protobuf/proto"
@@ -25,16 +19,12 @@ type HTTPClient interface {
 
 // BusClient holds information between API calls
 type BusClient struct {
-	url       string
-	username  string
-	password  string
-	userAgent string
-	client    HTTPClient
-
-	token string
+	url     string
+	session *Session
+	instrumentation
 }
 
-// NewBusClient creates new BusClient
+// NewBusClient creates new BusClient with its own Session and logs in
 func NewBusClient(
 	url,
 	username,
@@ -42,13 +32,8 @@ func NewBusClient(
 	userAgent string,
 	client HTTPClient,
 ) (*BusClient, error) {
-	bc := &BusClient{
-		url:       url,
-		username:  username,
-		password:  password,
-		userAgent: userAgent,
-		client:    client,
-	}
+	session := NewSession(url, StaticCredentials{Username: username, Password: password}, userAgent, client)
+	bc := NewBusClientWithSession(url, session)
 
 	err := bc.AuthenticateUser()
 	if err != nil {
@@ -58,57 +43,21 @@ func NewBusClient(
 	return bc, nil
 }
 
-// AuthenticateUser gets token for user
-func (bc *BusClient) AuthenticateUser() error {
-	// set username and password as data-url-encoded
-	body := url.Values{}
-	body.Add("username", bc.username)
-	body.Add("password", bc.password)
-	b := body.Encode()
-
-	url := bc.url + "authenticateUser"
-
-	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(b))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
-	req.Header.Set("User-Agent", bc.userAgent)
-
-	resp, err := bc.client.Do(req)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	respb := bytes.Buffer{}
-	_, err = io.Copy(&respb, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to authenticate user, status code: %d", resp.StatusCode)
-	}
-
-	var response struct {
-		Authenticated string `json:"Authenticated"`
-		UserToken     string `json:"UserToken"`
-	}
-
-	err = json.NewDecoder(&respb).Decode(&response)
-	if err != nil {
-		return fmt.Errorf("failed to decode response: %v, body: %s", err, respb.String())
-	}
-
-	if response.Authenticated != "True" {
-		return fmt.Errorf("failed to authenticate user")
+// NewBusClientWithSession creates new BusClient sharing session with other clients,
+// session logs in on the first API call
+func NewBusClientWithSession(url string, session *Session) *BusClient {
+	return &BusClient{
+		url:     url,
+		session: session,
+		instrumentation: instrumentation{
+			client: "BusClient",
+		},
 	}
+}
 
-	bc.token = response.UserToken
-	return nil
+// AuthenticateUser gets new token for the session
+func (bc *BusClient) AuthenticateUser() error {
+	return bc.session.loginFor(&bc.instrumentation)
 }
 
 func (bc *BusClient) GetGTFS() ([]byte, error) {
@@ -133,45 +82,7 @@ func (bc *BusClient) GetAlerts() (*gtfs.FeedMessage, error) {
 }
 
 func (bc *BusClient) callAPI(url string) ([]byte, error) {
-	reqBody := &bytes.Buffer{}
-	writer := multipart.NewWriter(reqBody)
-	err := writer.WriteField("token", bc.token)
-	if err != nil {
-		return nil, fmt.Errorf("write: %v", err)
-	}
-	err = writer.Close()
-	if err != nil {
-		return nil, fmt.Errorf("close writer: %v", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, bc.url+url, reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("create request: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "multipart/form-data; boundary="+writer.Boundary())
-	req.Header.Set("User-Agent", bc.userAgent)
-	req.Header.Set("Accept", "*/*")
-
-	resp, err := bc.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("call API: %v", err)
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status code: %d", resp.StatusCode)
-	}
-
-	body := bytes.Buffer{}
-	_, err = body.ReadFrom(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response body: %v", err)
-	}
-
-	return body.Bytes(), nil
-
+	return bc.session.post(&bc.instrumentation, bc.url+url, nil)
 }
 
 func (bc *BusClient) callAPIProto(url string) (*gtfs.FeedMessage, error) {
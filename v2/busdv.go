@@ -4,12 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"mime/multipart"
-	"net/http"
-	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
@@ -19,13 +16,12 @@ const (
 
 // BusDV2Client holds information between API calls
 type BusDV2Client struct {
-	url       string
-	username  string
-	password  string
-	userAgent string
-	client    HTTPClient
+	url     string
+	session *Session
+	instrumentation
 
-	token string
+	directionsMu sync.Mutex
+	directions   map[string]BusDirections // by route, see directionName
 }
 
 type DVTrip struct {
@@ -56,15 +52,130 @@ type VehicleLocation struct {
 }
 
 type GetBusDVResponse struct {
-	Message struct {
-		Message string `json:"message"`
-	} `json:"message"`
-	DVTrip DVTrip `json:"DVTrip"`
+	Message BusDVMessages `json:"message"`
+	DVTrip  DVTrips       `json:"DVTrip"`
+}
+
+type BusDVMessage struct {
+	Message string `json:"message"`
+}
+
+// BusDVMessages decodes "message" of getBusDV response, which is returned
+// as an object, an array of objects, a plain string or an empty string;
+// empty message is decoded as no messages
+type BusDVMessages []BusDVMessage
+
+func (m *BusDVMessages) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	switch {
+	case bytes.Equal(b, []byte("null")):
+		*m = nil
+	case len(b) > 0 && b[0] == '"':
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*m = nil
+		if s != "" {
+			*m = BusDVMessages{{Message: s}}
+		}
+	case len(b) > 0 && b[0] == '{':
+		var message BusDVMessage
+		if err := json.Unmarshal(b, &message); err != nil {
+			return err
+		}
+		*m = nil
+		if message.Message != "" {
+			*m = BusDVMessages{message}
+		}
+	default:
+		var messages []BusDVMessage
+		if err := json.Unmarshal(b, &messages); err != nil {
+			return err
+		}
+		*m = messages
+	}
+	return nil
+}
+
+// DVTrips decodes "DVTrip" of getBusDV response, which is returned
+// as an array of trips, a single trip object or an empty string when there are no departures
+type DVTrips []DVTrip
+
+func (t *DVTrips) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	switch {
+	case bytes.Equal(b, []byte("null")), bytes.Equal(b, []byte(`""`)):
+		*t = nil
+	case len(b) > 0 && b[0] == '{':
+		var trip DVTrip
+		if err := json.Unmarshal(b, &trip); err != nil {
+			return err
+		}
+		*t = DVTrips{trip}
+	default:
+		var trips []DVTrip
+		if err := json.Unmarshal(b, &trips); err != nil {
+			return err
+		}
+		*t = trips
+	}
+	return nil
 }
 
 type GetVehicleLocations []VehicleLocation
 
-// NewBusDV2Client creates new BusDV2Client
+// Location is a bus terminal with departure vision screen
+type Location struct {
+	Code string `json:"bus_terminal_code"`
+	Name string `json:"bus_terminal_name"`
+}
+
+type GetLocations []Location
+
+type BusRoute struct {
+	ID          string `json:"BusRouteID"`
+	Description string `json:"BusRouteDescription"`
+	Mode        string `json:"BusRouteMode"`
+}
+
+type GetBusRoutes []BusRoute
+
+// BusDirections holds both direction names of a route, used as direction in GetStops and GetBusDV
+type BusDirections struct {
+	Direction1 string `json:"Direction_1"`
+	Direction2 string `json:"Direction_2"`
+}
+
+type GetBusDirectionsData []BusDirections
+
+type BusStop struct {
+	Description string `json:"busstopdescription"`
+	Number      string `json:"busstopnumber"`
+}
+
+type GetStops []BusStop
+
+type GetRouteTrips []DVTrip
+
+// TripStop is a stop served by a trip with scheduled and predicted times
+type TripStop struct {
+	StopID         string `json:"StopID"`
+	StopName       string `json:"StopName"`
+	Time           string `json:"Time"`       // scheduled time
+	ApproxTime     string `json:"ApproxTime"` // predicted time, empty if no prediction
+	Status         string `json:"Status"`
+	DepartedStatus string `json:"DepartedStatus"`
+	TimingPoint    string `json:"TimingPoint"`
+}
+
+type GetTripStops []TripStop
+
+type GetStopNameResponse struct {
+	StopName string `json:"stopName"`
+}
+
+// NewBusDV2Client creates new BusDV2Client with its own Session and logs in
 func NewBusDV2Client(
 	url,
 	username,
@@ -72,13 +183,8 @@ func NewBusDV2Client(
 	userAgent string,
 	client HTTPClient,
 ) (*BusDV2Client, error) {
-	bc := &BusDV2Client{
-		url:       url,
-		username:  username,
-		password:  password,
-		userAgent: userAgent,
-		client:    client,
-	}
+	session := NewSession(url, StaticCredentials{Username: username, Password: password}, userAgent, client)
+	bc := NewBusDV2ClientWithSession(url, session)
 
 	err := bc.AuthenticateUser()
 	if err != nil {
@@ -88,77 +194,101 @@ func NewBusDV2Client(
 	return bc, nil
 }
 
-// AuthenticateUser gets token for user
+// NewBusDV2ClientWithSession creates new BusDV2Client sharing session with other clients,
+// session logs in on the first API call
+func NewBusDV2ClientWithSession(url string, session *Session) *BusDV2Client {
+	return &BusDV2Client{
+		url:     url,
+		session: session,
+		instrumentation: instrumentation{
+			client: "BusDV2Client",
+		},
+	}
+}
+
+// AuthenticateUser gets new token for the session
 func (bc *BusDV2Client) AuthenticateUser() error {
-	// set username and password as data-url-encoded
-	body := url.Values{}
-	body.Add("username", bc.username)
-	body.Add("password", bc.password)
-	b := body.Encode()
+	return bc.session.loginFor(&bc.instrumentation)
+}
 
-	url := bc.url + "authenticateUser"
-	log.Printf("→ %s", url)
+// GetBusDV returns departures from the stop, empty arguments are not sent
+//
+// Deprecated: use GetBusDVWithRequest, which validates the request and resolves direction names
+func (bc *BusDV2Client) GetBusDV(stop, direction, route, ip string) (*GetBusDVResponse, error) {
+	return bc.GetBusDVWithRequest(GetBusDVRequest{Stop: stop, Route: route, DirectionName: direction, IP: ip})
+}
 
-	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(b))
+// GetBusDVWithRequest returns departures from the stop. Request is validated before any API call,
+// direction name is looked up once per route if request.Direction is set.
+func (bc *BusDV2Client) GetBusDVWithRequest(request GetBusDVRequest) (*GetBusDVResponse, error) {
+	err := request.Validate()
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid request: %v", err)
 	}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
-	req.Header.Set("User-Agent", bc.userAgent)
+	direction := request.DirectionName
+	if request.Direction != AnyDirection {
+		direction, err = bc.directionName(request.Route, request.Direction)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	resp, err := bc.client.Do(req)
+	response := &GetBusDVResponse{}
+	err = bc.callAPIJSON("getBusDV", request.fields(direction), response)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("callAPI: %v", err)
 	}
 
-	defer resp.Body.Close()
+	return response, nil
+}
 
-	respb := bytes.Buffer{}
-	_, err = io.Copy(&respb, resp.Body)
+// GetVehicleLocations returns vehicles within radius feet of lat, lon
+//
+// Deprecated: use GetVehicleLocationsWithRequest, which takes typed coordinates, radius and mode
+func (bc *BusDV2Client) GetVehicleLocations(lat, lon string, radius int, mode string) (*GetVehicleLocations, error) {
+	latValue, err := strconv.ParseFloat(strings.TrimSpace(lat), 64)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
+		return nil, fmt.Errorf("invalid lat: %q", lat)
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to authenticate user, status code: %d", resp.StatusCode)
+	lonValue, err := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lon: %q", lon)
 	}
 
-	var response struct {
-		Authenticated string `json:"Authenticated"`
-		UserToken     string `json:"UserToken"`
-	}
+	return bc.GetVehicleLocationsWithRequest(GetVehicleLocationsRequest{
+		Lat:    latValue,
+		Lon:    lonValue,
+		Radius: Distance(radius) * Foot,
+		Mode:   Mode(mode),
+	})
+}
 
-	err = json.NewDecoder(&respb).Decode(&response)
+// GetVehicleLocationsWithRequest returns vehicles within the radius, request is validated before any API call
+func (bc *BusDV2Client) GetVehicleLocationsWithRequest(request GetVehicleLocationsRequest) (*GetVehicleLocations, error) {
+	err := request.Validate()
 	if err != nil {
-		return fmt.Errorf("failed to decode response: %v, body: %s", err, respb.String())
+		return nil, fmt.Errorf("invalid request: %v", err)
 	}
 
-	if response.Authenticated != "True" {
-		return fmt.Errorf("failed to authenticate user")
+	response := &GetVehicleLocations{}
+	err = bc.callAPIJSON("getVehicleLocations", request.fields(), response)
+	if err != nil {
+		return nil, fmt.Errorf("callAPI: %v", err)
 	}
 
-	bc.token = response.UserToken
-	return nil
+	return response, nil
 }
 
-func (bc *BusDV2Client) GetBusDV(stop, direction, route, ip string) (*GetBusDVResponse, error) {
-	var pairs []string
-	if stop != "" {
-		pairs = append(pairs, "stop", stop)
-	}
-	if direction != "" {
-		pairs = append(pairs, "direction", direction)
-	}
-	if route != "" {
-		pairs = append(pairs, "route", route)
-	}
-	if ip != "" {
-		pairs = append(pairs, "ip", ip)
+// GetLocations returns bus terminals, request is validated before any API call
+func (bc *BusDV2Client) GetLocations(request GetLocationsRequest) (*GetLocations, error) {
+	err := request.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %v", err)
 	}
 
-	response := &GetBusDVResponse{}
-	err := bc.callAPIJSON("getBusDV", pairs, response)
+	response := &GetLocations{}
+	err = bc.callAPIJSON("getLocations", request.fields(), response)
 	if err != nil {
 		return nil, fmt.Errorf("callAPI: %v", err)
 	}
@@ -166,23 +296,15 @@ func (bc *BusDV2Client) GetBusDV(stop, direction, route, ip string) (*GetBusDVRe
 	return response, nil
 }
 
-func (bc *BusDV2Client) GetVehicleLocations(lat, lon string, radius int, mode string) (*GetVehicleLocations, error) {
-	var pairs []string
-	if lat != "" {
-		pairs = append(pairs, "lat", lat)
-	}
-	if lon != "" {
-		pairs = append(pairs, "lon", lon)
-	}
-	if radius != 0 {
-		pairs = append(pairs, "radius", fmt.Sprintf("%d", radius))
-	}
-	if mode != "" {
-		pairs = append(pairs, "mode", mode)
+// GetBusRoutes returns all routes, request is validated before any API call
+func (bc *BusDV2Client) GetBusRoutes(request GetBusRoutesRequest) (*GetBusRoutes, error) {
+	err := request.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %v", err)
 	}
 
-	response := &GetVehicleLocations{}
-	err := bc.callAPIJSON("getVehicleLocations", pairs, response)
+	response := &GetBusRoutes{}
+	err = bc.callAPIJSON("getBusRoutes", request.fields(), response)
 	if err != nil {
 		return nil, fmt.Errorf("callAPI: %v", err)
 	}
@@ -190,57 +312,120 @@ func (bc *BusDV2Client) GetVehicleLocations(lat, lon string, radius int, mode st
 	return response, nil
 }
 
-func (bc *BusDV2Client) callAPI(url string, bodyPairs []string) ([]byte, error) {
-	reqBody := &bytes.Buffer{}
-	writer := multipart.NewWriter(reqBody)
-	err := writer.WriteField("token", bc.token)
+// GetBusDirectionsData returns direction names of the route
+func (bc *BusDV2Client) GetBusDirectionsData(route string) (*GetBusDirectionsData, error) {
+	response := &GetBusDirectionsData{}
+	err := bc.callAPIJSON("getBusDirectionsData", []string{"route", route}, response)
 	if err != nil {
-		return nil, fmt.Errorf("write: %v", err)
+		return nil, fmt.Errorf("callAPI: %v", err)
 	}
 
-	if len(bodyPairs)%2 != 0 {
-		return nil, fmt.Errorf("bodyPairs must be even")
+	return response, nil
+}
+
+// GetStops returns stops of the route in the direction, request is validated before any API call,
+// direction name is looked up once per route if request.Direction is set
+func (bc *BusDV2Client) GetStops(request GetStopsRequest) (*GetStops, error) {
+	err := request.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %v", err)
 	}
-	for i := 0; i < len(bodyPairs); i += 2 {
-		err = writer.WriteField(bodyPairs[i], bodyPairs[i+1])
+
+	direction := request.DirectionName
+	if request.Direction != AnyDirection {
+		direction, err = bc.directionName(request.Route, request.Direction)
 		if err != nil {
-			return nil, fmt.Errorf("write: %v", err)
+			return nil, err
 		}
 	}
 
-	err = writer.Close()
+	response := &GetStops{}
+	err = bc.callAPIJSON("getStops", request.fields(direction), response)
+	if err != nil {
+		return nil, fmt.Errorf("callAPI: %v", err)
+	}
+
+	return response, nil
+}
+
+// directionName returns direction name of the route, names are fetched
+// with GetBusDirectionsData on the first use of the route and kept by the client
+func (bc *BusDV2Client) directionName(route string, direction Direction) (string, error) {
+	bc.directionsMu.Lock()
+	directions, ok := bc.directions[route]
+	bc.directionsMu.Unlock()
+	if ok {
+		return directions.Name(direction), nil
+	}
+
+	data, err := bc.GetBusDirectionsData(route)
 	if err != nil {
-		return nil, fmt.Errorf("close writer: %v", err)
+		return "", fmt.Errorf("GetBusDirectionsData: %v", err)
+	}
+	if len(*data) == 0 {
+		return "", fmt.Errorf("no directions for route %s", route)
+	}
+	directions = (*data)[0]
+
+	bc.directionsMu.Lock()
+	if bc.directions == nil {
+		bc.directions = map[string]BusDirections{}
 	}
+	bc.directions[route] = directions
+	bc.directionsMu.Unlock()
 
-	log.Printf("→ %s", bc.url+url)
-	req, err := http.NewRequest(http.MethodPost, bc.url+url, reqBody)
+	return directions.Name(direction), nil
+}
+
+// GetStopName returns name of the stop by its number
+func (bc *BusDV2Client) GetStopName(stopNumber string) (*GetStopNameResponse, error) {
+	response := &GetStopNameResponse{}
+	err := bc.callAPIJSON("getStopName", []string{"stopnum", stopNumber}, response)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %v", err)
+		return nil, fmt.Errorf("callAPI: %v", err)
 	}
 
-	req.Header.Set("Content-Type", "multipart/form-data; boundary="+writer.Boundary())
-	req.Header.Set("User-Agent", bc.userAgent)
-	req.Header.Set("Accept", "*/*")
+	return response, nil
+}
 
-	resp, err := bc.client.Do(req)
+// GetRouteTrips returns trips of the route departing from the location (see GetLocations)
+func (bc *BusDV2Client) GetRouteTrips(location, route string) (*GetRouteTrips, error) {
+	response := &GetRouteTrips{}
+	err := bc.callAPIJSON("getRouteTrips", []string{"location", location, "route", route}, response)
 	if err != nil {
-		return nil, fmt.Errorf("call API: %v", err)
+		return nil, fmt.Errorf("callAPI: %v", err)
 	}
 
-	defer resp.Body.Close()
+	return response, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status code: %d", resp.StatusCode)
+// GetTripStops returns stops served by the trip starting from stop sudo
+// (timing point ID from DVTrip), all stops of the trip if sudo is empty
+func (bc *BusDV2Client) GetTripStops(internalTripNumber, sudo string) (*GetTripStops, error) {
+	pairs := []string{"internal_trip_number", internalTripNumber}
+	if sudo != "" {
+		pairs = append(pairs, "sudo", sudo)
 	}
 
-	body := bytes.Buffer{}
-	_, err = body.ReadFrom(resp.Body)
+	response := &GetTripStops{}
+	err := bc.callAPIJSON("getTripStops", pairs, response)
 	if err != nil {
-		return nil, fmt.Errorf("read response body: %v", err)
+		return nil, fmt.Errorf("callAPI: %v", err)
 	}
 
-	return body.Bytes(), nil
+	return response, nil
+}
+
+// GetDVTripStops returns stop list of DVTrip returned by GetBusDV or GetRouteTrips
+func (bc *BusDV2Client) GetDVTripStops(trip DVTrip) (*GetTripStops, error) {
+	if trip.InternalTripNum == "" {
+		return nil, fmt.Errorf("trip has no internal trip number")
+	}
+	return bc.GetTripStops(trip.InternalTripNum, trip.TimingPointID)
+}
+
+func (bc *BusDV2Client) callAPI(url string, bodyPairs []string) ([]byte, error) {
+	return bc.session.post(&bc.instrumentation, bc.url+url, bodyPairs)
 }
 
 func (bc *BusDV2Client) callAPIJSON(url string, bodyPairs []string, v interface{}) error {
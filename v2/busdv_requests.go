@@ -0,0 +1,225 @@
+package njtransit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Mode of transportation of BusDV2Client requests, accepted modes differ by API method
+type Mode string
+
+// Modes
+const (
+	ModeBus                   Mode = "BUS"
+	ModeTrain                 Mode = "TRAIN"      // GetVehicleLocations only
+	ModeLightRail             Mode = "LIGHT RAIL" // GetVehicleLocations only
+	ModeNewarkLightRail       Mode = "NLR"        // GetLocations and GetBusRoutes only
+	ModeHudsonBergenLightRail Mode = "HBLR"       // GetLocations and GetBusRoutes only
+	ModeRiverLine             Mode = "RL"         // GetLocations and GetBusRoutes only
+	ModeAll                   Mode = "ALL"
+)
+
+// vehicleLocationModes are modes accepted by getVehicleLocations
+var vehicleLocationModes = []Mode{ModeBus, ModeTrain, ModeLightRail, ModeAll}
+
+// lineModes are modes accepted by getLocations and getBusRoutes
+var lineModes = []Mode{ModeBus, ModeNewarkLightRail, ModeHudsonBergenLightRail, ModeRiverLine, ModeAll}
+
+func (m Mode) oneOf(modes []Mode) bool {
+	for _, mode := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Direction is one of two directions of a route returned by BusDV2Client.GetBusDirectionsData
+type Direction int
+
+// Directions
+const (
+	AnyDirection Direction = iota
+	Direction1
+	Direction2
+)
+
+// Name returns name of the direction from d, empty for AnyDirection
+func (d BusDirections) Name(direction Direction) string {
+	switch direction {
+	case Direction1:
+		return d.Direction1
+	case Direction2:
+		return d.Direction2
+	}
+	return ""
+}
+
+// Distance is a length in feet, the unit used by BUSDV2 API
+type Distance float64
+
+// Distance units, e.g. 2 * njt.Mile
+const (
+	Foot  Distance = 1
+	Meter Distance = 3.280839895
+	Mile  Distance = 5280
+)
+
+// Feet returns distance as whole number of feet
+func (d Distance) Feet() int {
+	return int(math.Round(float64(d)))
+}
+
+// GetBusDVRequest represents getBusDV API request
+type GetBusDVRequest struct {
+	Stop          string    // stop number, required
+	Route         string    // optional, required if Direction is set
+	Direction     Direction // optional, resolved to direction name of Route, see GetBusDirectionsData
+	DirectionName string    // optional, sent as is without lookup, can't be used with Direction
+	IP            string    // optional
+}
+
+// Validate checks request before any API call
+func (r GetBusDVRequest) Validate() error {
+	if r.Stop == "" {
+		return fmt.Errorf("stop is required")
+	}
+	if r.Direction < AnyDirection || r.Direction > Direction2 {
+		return fmt.Errorf("invalid direction: %d", r.Direction)
+	}
+	if r.Direction != AnyDirection && r.DirectionName != "" {
+		return fmt.Errorf("direction and direction name are mutually exclusive")
+	}
+	if r.Direction != AnyDirection && r.Route == "" {
+		return fmt.Errorf("route is required to filter by direction")
+	}
+	return nil
+}
+
+func (r GetBusDVRequest) fields(direction string) []string {
+	pairs := []string{"stop", r.Stop}
+	if direction != "" {
+		pairs = append(pairs, "direction", direction)
+	}
+	if r.Route != "" {
+		pairs = append(pairs, "route", r.Route)
+	}
+	if r.IP != "" {
+		pairs = append(pairs, "ip", r.IP)
+	}
+	return pairs
+}
+
+// GetVehicleLocationsRequest represents getVehicleLocations API request
+type GetVehicleLocationsRequest struct {
+	Lat    float64
+	Lon    float64
+	Radius Distance
+	Mode   Mode // ModeAll if empty
+}
+
+// Validate checks request before any API call
+func (r GetVehicleLocationsRequest) Validate() error {
+	if math.IsNaN(r.Lat) || r.Lat < -90 || r.Lat > 90 {
+		return fmt.Errorf("invalid lat: %v", r.Lat)
+	}
+	if math.IsNaN(r.Lon) || r.Lon < -180 || r.Lon > 180 {
+		return fmt.Errorf("invalid lon: %v", r.Lon)
+	}
+	if r.Lat == 0 && r.Lon == 0 {
+		return fmt.Errorf("lat and lon are required")
+	}
+	if r.Radius.Feet() <= 0 {
+		return fmt.Errorf("radius must be at least 1 foot: %v", r.Radius)
+	}
+	if r.Mode != "" && !r.Mode.oneOf(vehicleLocationModes) {
+		return fmt.Errorf("invalid mode: %q", r.Mode)
+	}
+	return nil
+}
+
+func (r GetVehicleLocationsRequest) fields() []string {
+	mode := r.Mode
+	if mode == "" {
+		mode = ModeAll
+	}
+	return []string{
+		"lat", strconv.FormatFloat(r.Lat, 'f', -1, 64),
+		"lon", strconv.FormatFloat(r.Lon, 'f', -1, 64),
+		"radius", strconv.Itoa(r.Radius.Feet()),
+		"mode", string(mode),
+	}
+}
+
+// GetLocationsRequest represents getLocations API request
+type GetLocationsRequest struct {
+	Mode Mode // optional, one of ModeBus, ModeNewarkLightRail, ModeHudsonBergenLightRail, ModeRiverLine or ModeAll
+}
+
+// Validate checks request before any API call
+func (r GetLocationsRequest) Validate() error {
+	return validateLineMode(r.Mode)
+}
+
+func (r GetLocationsRequest) fields() []string {
+	return lineModeFields(r.Mode)
+}
+
+// GetBusRoutesRequest represents getBusRoutes API request
+type GetBusRoutesRequest struct {
+	Mode Mode // optional, one of ModeBus, ModeNewarkLightRail, ModeHudsonBergenLightRail, ModeRiverLine or ModeAll
+}
+
+// Validate checks request before any API call
+func (r GetBusRoutesRequest) Validate() error {
+	return validateLineMode(r.Mode)
+}
+
+func (r GetBusRoutesRequest) fields() []string {
+	return lineModeFields(r.Mode)
+}
+
+func validateLineMode(mode Mode) error {
+	if mode != "" && !mode.oneOf(lineModes) {
+		return fmt.Errorf("invalid mode: %q", mode)
+	}
+	return nil
+}
+
+func lineModeFields(mode Mode) []string {
+	if mode == "" {
+		return nil
+	}
+	return []string{"mode", string(mode)}
+}
+
+// GetStopsRequest represents getStops API request
+type GetStopsRequest struct {
+	Route         string    // required
+	Direction     Direction // resolved to direction name of Route, see GetBusDirectionsData
+	DirectionName string    // sent as is without lookup, required if Direction is not set
+	NameContains  string    // optional, filters stops by description
+}
+
+// Validate checks request before any API call
+func (r GetStopsRequest) Validate() error {
+	if r.Route == "" {
+		return fmt.Errorf("route is required")
+	}
+	if r.Direction < AnyDirection || r.Direction > Direction2 {
+		return fmt.Errorf("invalid direction: %d", r.Direction)
+	}
+	if (r.Direction == AnyDirection) == (r.DirectionName == "") {
+		return fmt.Errorf("either direction or direction name is required")
+	}
+	return nil
+}
+
+func (r GetStopsRequest) fields(direction string) []string {
+	pairs := []string{"route", r.Route, "direction", direction}
+	if r.NameContains != "" {
+		pairs = append(pairs, "namecontains", r.NameContains)
+	}
+	return pairs
+}
@@ -0,0 +1,197 @@
+package njtransit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBusDV serves canned responses by endpoint name and records form values of requests
+type fakeBusDV struct {
+	responses map[string]string
+	forms     map[string]map[string]string
+}
+
+func (f *fakeBusDV) Do(req *http.Request) (*http.Response, error) {
+	endpoint := path.Base(req.URL.Path)
+	if f.forms == nil {
+		f.forms = map[string]map[string]string{}
+	}
+	if endpoint == "authenticateUser" {
+		return f.response(`{"Authenticated":"True","UserToken":"token"}`), nil
+	}
+
+	err := req.ParseMultipartForm(1 << 20)
+	if err != nil {
+		return nil, err
+	}
+	form := map[string]string{}
+	for k, v := range req.MultipartForm.Value {
+		form[k] = v[0]
+	}
+	f.forms[endpoint] = form
+
+	body, ok := f.responses[endpoint]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(&bytes.Buffer{})}, nil
+	}
+	return f.response(body), nil
+}
+
+func (f *fakeBusDV) response(body string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(body))}
+}
+
+func newTestBusDV2Client(t *testing.T, responses map[string]string) (*BusDV2Client, *fakeBusDV) {
+	fake := &fakeBusDV{responses: responses}
+	client, err := NewBusDV2Client(BusDVTestURL, "user", "pass", "test", fake)
+	assert.NoError(t, err)
+	return client, fake
+}
+
+func TestBusDV2ClientDiscovery(t *testing.T) {
+	client, fake := newTestBusDV2Client(t, map[string]string{
+		"getLocations":         `[{"bus_terminal_code":"PABT","bus_terminal_name":"Port Authority Bus Terminal"}]`,
+		"getBusRoutes":         `[{"BusRouteID":"1","BusRouteDescription":"Newark - Ivy Hill","BusRouteMode":"NJB"}]`,
+		"getBusDirectionsData": `[{"Direction_1":"Newark","Direction_2":"Ivy Hill"}]`,
+		"getStops":             `[{"busstopdescription":"BROAD ST AT MARKET ST","busstopnumber":"19159"}]`,
+		"getStopName":          `{"stopName":"BROAD ST AT MARKET ST"}`,
+	})
+
+	locations, err := client.GetLocations(GetLocationsRequest{Mode: ModeBus})
+	assert.NoError(t, err)
+	assert.Equal(t, GetLocations{{Code: "PABT", Name: "Port Authority Bus Terminal"}}, *locations)
+
+	routes, err := client.GetBusRoutes(GetBusRoutesRequest{Mode: ModeBus})
+	assert.NoError(t, err)
+	assert.Equal(t, GetBusRoutes{{ID: "1", Description: "Newark - Ivy Hill", Mode: "NJB"}}, *routes)
+
+	directions, err := client.GetBusDirectionsData("1")
+	assert.NoError(t, err)
+	assert.Equal(t, GetBusDirectionsData{{Direction1: "Newark", Direction2: "Ivy Hill"}}, *directions)
+
+	stops, err := client.GetStops(GetStopsRequest{Route: "1", Direction: Direction1})
+	assert.NoError(t, err)
+	assert.Equal(t, GetStops{{Description: "BROAD ST AT MARKET ST", Number: "19159"}}, *stops)
+	assert.Equal(t, map[string]string{"token": "token", "route": "1", "direction": "Newark"}, fake.forms["getStops"])
+
+	name, err := client.GetStopName("19159")
+	assert.NoError(t, err)
+	assert.Equal(t, "BROAD ST AT MARKET ST", name.StopName)
+}
+
+func TestBusDV2ClientTrips(t *testing.T) {
+	client, fake := newTestBusDV2Client(t, map[string]string{
+		"getRouteTrips": `[{"public_route":"1","header":"1 NEWARK","internal_trip_number":"19624134","sched_dep_time":"6/22/2023 12:40:00 AM","timing_point_id":"17142"}]`,
+		"getTripStops":  `[{"StopID":"17142","StopName":"IVY HILL","Time":"12:40 AM","ApproxTime":"12:43 AM","Status":"Approaching"}]`,
+	})
+
+	trips, err := client.GetRouteTrips("PABT", "1")
+	assert.NoError(t, err)
+	assert.Len(t, *trips, 1)
+
+	stops, err := client.GetDVTripStops((*trips)[0])
+	assert.NoError(t, err)
+	assert.Equal(t, GetTripStops{{StopID: "17142", StopName: "IVY HILL", Time: "12:40 AM", ApproxTime: "12:43 AM", Status: "Approaching"}}, *stops)
+	assert.Equal(t, map[string]string{"token": "token", "internal_trip_number": "19624134", "sudo": "17142"}, fake.forms["getTripStops"])
+
+	_, err = client.GetDVTripStops(DVTrip{})
+	assert.Error(t, err)
+}
+
+func TestGetBusDVResponse(t *testing.T) {
+	for _, tc := range []struct {
+		fixture  string
+		trips    []string
+		messages BusDVMessages
+	}{
+		{fixture: "getBusDV_trips.json", trips: []string{"19624134", "19624135"}},
+		{fixture: "getBusDV_single.json", trips: []string{"19624200"}, messages: BusDVMessages{{Message: "Detour in effect on Broad St"}, {Message: "Use stop 19160"}}},
+		{fixture: "getBusDV_empty.json"},
+	} {
+		t.Run(tc.fixture, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join("testdata", tc.fixture))
+			assert.NoError(t, err)
+
+			client, _ := newTestBusDV2Client(t, map[string]string{"getBusDV": string(b)})
+			response, err := client.GetBusDVWithRequest(GetBusDVRequest{Stop: "19159"})
+			assert.NoError(t, err)
+
+			var trips []string
+			for _, trip := range response.DVTrip {
+				trips = append(trips, trip.InternalTripNum)
+			}
+			assert.Equal(t, tc.trips, trips)
+			assert.Equal(t, tc.messages, response.Message)
+		})
+	}
+
+	var messages BusDVMessages
+	assert.NoError(t, json.Unmarshal([]byte(`"No arrivals"`), &messages))
+	assert.Equal(t, BusDVMessages{{Message: "No arrivals"}}, messages)
+}
+
+func TestBusDV2ClientRequests(t *testing.T) {
+	client, fake := newTestBusDV2Client(t, map[string]string{
+		"getBusDirectionsData": `[{"Direction_1":"Newark","Direction_2":"Ivy Hill"}]`,
+		"getBusDV":             `{"message":"","DVTrip":""}`,
+		"getVehicleLocations":  `[]`,
+	})
+
+	_, err := client.GetBusDVWithRequest(GetBusDVRequest{Stop: "19159", Route: "1", Direction: Direction2})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"token": "token", "stop": "19159", "route": "1", "direction": "Ivy Hill"}, fake.forms["getBusDV"])
+
+	// direction names are fetched once per route
+	delete(fake.forms, "getBusDirectionsData")
+	_, err = client.GetBusDVWithRequest(GetBusDVRequest{Stop: "19159", Route: "1", Direction: Direction1})
+	assert.NoError(t, err)
+	assert.Equal(t, "Newark", fake.forms["getBusDV"]["direction"])
+	assert.NotContains(t, fake.forms, "getBusDirectionsData")
+
+	// direction name is sent without lookup
+	_, err = client.GetBusDVWithRequest(GetBusDVRequest{Stop: "19159", Route: "2", DirectionName: "Newark"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Newark", fake.forms["getBusDV"]["direction"])
+	assert.NotContains(t, fake.forms, "getBusDirectionsData")
+
+	// deprecated positional methods
+	_, err = client.GetBusDV("19159", "Ivy Hill", "1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"token": "token", "stop": "19159", "route": "1", "direction": "Ivy Hill"}, fake.forms["getBusDV"])
+	_, err = client.GetVehicleLocations("40.737169", "-74.169868", 2000, "BUS")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"token": "token", "lat": "40.737169", "lon": "-74.169868", "radius": "2000", "mode": "BUS"}, fake.forms["getVehicleLocations"])
+	_, err = client.GetVehicleLocations("", "-74.169868", 2000, "BUS")
+	assert.Error(t, err)
+
+	_, err = client.GetVehicleLocationsWithRequest(GetVehicleLocationsRequest{Lat: 40.737169, Lon: -74.169868, Radius: 0.5 * Mile})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"token": "token", "lat": "40.737169", "lon": "-74.169868", "radius": "2640", "mode": "ALL"}, fake.forms["getVehicleLocations"])
+
+	for _, request := range []GetVehicleLocationsRequest{
+		{Lat: 91, Lon: -74.169868, Radius: Mile},
+		{Lat: 40.737169, Lon: -74.169868},
+		{Lat: 40.737169, Lon: -74.169868, Radius: Mile, Mode: "SUBWAY"},
+	} {
+		assert.Error(t, request.Validate())
+	}
+	assert.Error(t, GetVehicleLocationsRequest{Lat: 40.737169, Lon: -74.169868, Radius: Mile, Mode: ModeRiverLine}.Validate())
+	assert.NoError(t, GetLocationsRequest{Mode: ModeRiverLine}.Validate())
+	assert.Error(t, GetLocationsRequest{Mode: ModeTrain}.Validate())
+	assert.Error(t, GetBusRoutesRequest{Mode: "SUBWAY"}.Validate())
+	assert.Error(t, GetStopsRequest{Route: "1"}.Validate())
+	assert.Error(t, GetStopsRequest{Direction: Direction1}.Validate())
+	assert.Error(t, GetStopsRequest{Route: "1", Direction: Direction1, DirectionName: "Newark"}.Validate())
+	assert.NoError(t, GetStopsRequest{Route: "1", DirectionName: "Newark"}.Validate())
+	assert.Error(t, GetBusDVRequest{Stop: "19159", Route: "1", Direction: Direction1, DirectionName: "Newark"}.Validate())
+	assert.Error(t, GetBusDVRequest{}.Validate())
+	assert.Error(t, GetBusDVRequest{Stop: "19159", Direction: Direction1}.Validate())
+}
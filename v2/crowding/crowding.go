@@ -0,0 +1,309 @@
+// Package crowding aggregates passenger load samples from BUSDV2 and GTFS-RT
+// into typical crowding per route and hour of day, and per trip.
+package crowding
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	njt "github.com/errornil/njtransit/v2"
+	gtfs "github.com/errornil/transit_realtime"
+)
+
+// levels is the number of ordered occupancy levels, EMPTY to NOT_ACCEPTING_PASSENGERS;
+// NO_DATA_AVAILABLE and NOT_BOARDABLE are not samples of load
+const levels = int(gtfs.VehiclePosition_NOT_ACCEPTING_PASSENGERS) + 1
+
+// Sample is a single passenger load observation
+type Sample struct {
+	Route     string
+	TripID    string // GTFS trip_id or BUSDV2 internal trip number, optional
+	Time      time.Time
+	Occupancy gtfs.VehiclePosition_OccupancyStatus
+}
+
+// Stats is typical crowding of a route in an hour of day, or of a trip
+type Stats struct {
+	Route   string
+	TripID  string // empty for route stats
+	Hour    int    // local hour of day, -1 for trip stats
+	Samples int
+	P50     gtfs.VehiclePosition_OccupancyStatus
+	P90     gtfs.VehiclePosition_OccupancyStatus
+}
+
+// histogram counts samples per occupancy level
+type histogram [levels]int
+
+func (h *histogram) total() int {
+	n := 0
+	for _, c := range h {
+		n += c
+	}
+	return n
+}
+
+func (h *histogram) percentile(p float64) gtfs.VehiclePosition_OccupancyStatus {
+	total := h.total()
+	rank := int(p * float64(total))
+	if rank >= total {
+		rank = total - 1
+	}
+	seen := 0
+	for level, c := range h {
+		seen += c
+		if seen > rank {
+			return gtfs.VehiclePosition_OccupancyStatus(level)
+		}
+	}
+	return gtfs.VehiclePosition_NO_DATA_AVAILABLE
+}
+
+type routeKey struct {
+	Route string
+	Hour  int
+}
+
+// Aggregator collects samples. Aggregator is safe for concurrent use.
+type Aggregator struct {
+	location *time.Location
+
+	mu         sync.RWMutex
+	routes     map[routeKey]*histogram
+	trips      map[string]*histogram // by trip ID
+	tripRoutes map[string]string
+}
+
+// NewAggregator creates new Aggregator, location is used for hour of day buckets,
+// America/New_York is used if nil
+func NewAggregator(location *time.Location) *Aggregator {
+	if location == nil {
+		location = njt.TimeZone()
+	}
+	return &Aggregator{
+		location:   location,
+		routes:     map[routeKey]*histogram{},
+		trips:      map[string]*histogram{},
+		tripRoutes: map[string]string{},
+	}
+}
+
+// Add adds a sample, samples without route or with unknown occupancy are ignored
+func (a *Aggregator) Add(s Sample) {
+	if s.Route == "" || s.Occupancy < 0 || int(s.Occupancy) >= levels {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := routeKey{Route: s.Route, Hour: s.Time.In(a.location).Hour()}
+	if a.routes[key] == nil {
+		a.routes[key] = &histogram{}
+	}
+	a.routes[key][s.Occupancy]++
+
+	if s.TripID != "" {
+		if a.trips[s.TripID] == nil {
+			a.trips[s.TripID] = &histogram{}
+		}
+		a.trips[s.TripID][s.Occupancy]++
+		a.tripRoutes[s.TripID] = s.Route
+	}
+}
+
+// AddVehicleLocations samples BusDV2Client.GetVehicleLocations response observed at t
+func (a *Aggregator) AddVehicleLocations(locations *njt.GetVehicleLocations, t time.Time) {
+	if locations == nil {
+		return
+	}
+	for _, l := range *locations {
+		a.Add(Sample{
+			Route:     strings.TrimSpace(l.VehicleRoute),
+			TripID:    strings.TrimSpace(l.VehicleInternalTripNumber),
+			Time:      t,
+			Occupancy: njt.ParsePassengerLoad(l.VehiclePassengerLoad),
+		})
+	}
+}
+
+// AddBusDV samples departures of BusDV2Client.GetBusDV response observed at t
+func (a *Aggregator) AddBusDV(response *njt.GetBusDVResponse, t time.Time) {
+	if response == nil {
+		return
+	}
+	for _, trip := range response.DVTrip {
+		a.Add(Sample{
+			Route:     strings.TrimSpace(trip.PublicRoute),
+			TripID:    strings.TrimSpace(trip.InternalTripNum),
+			Time:      t,
+			Occupancy: njt.ParsePassengerLoad(trip.PassLoad),
+		})
+	}
+}
+
+// AddFeed samples vehicle positions with occupancy status,
+// vehicles without timestamp get the header timestamp
+func (a *Aggregator) AddFeed(feed *gtfs.FeedMessage) {
+	for _, entity := range feed.GetEntity() {
+		vehicle := entity.GetVehicle()
+		if vehicle == nil || vehicle.OccupancyStatus == nil {
+			continue
+		}
+
+		ts := vehicle.GetTimestamp()
+		if ts == 0 {
+			ts = feed.GetHeader().GetTimestamp()
+		}
+		a.Add(Sample{
+			Route:     vehicle.GetTrip().GetRouteId(),
+			TripID:    vehicle.GetTrip().GetTripId(),
+			Time:      time.Unix(int64(ts), 0),
+			Occupancy: vehicle.GetOccupancyStatus(),
+		})
+	}
+}
+
+// Route returns crowding of the route in the local hour of day
+func (a *Aggregator) Route(route string, hour int) (Stats, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	h, ok := a.routes[routeKey{Route: route, Hour: hour}]
+	if !ok {
+		return Stats{}, false
+	}
+	return stats(route, "", hour, h), true
+}
+
+// Trip returns crowding of the trip
+func (a *Aggregator) Trip(tripID string) (Stats, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	h, ok := a.trips[tripID]
+	if !ok {
+		return Stats{}, false
+	}
+	return stats(a.tripRoutes[tripID], tripID, -1, h), true
+}
+
+// Routes returns crowding of all routes by hour, sorted by route and hour
+func (a *Aggregator) Routes() []Stats {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	result := make([]Stats, 0, len(a.routes))
+	for key, h := range a.routes {
+		result = append(result, stats(key.Route, "", key.Hour, h))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Route != result[j].Route {
+			return result[i].Route < result[j].Route
+		}
+		return result[i].Hour < result[j].Hour
+	})
+	return result
+}
+
+func stats(route, tripID string, hour int, h *histogram) Stats {
+	return Stats{
+		Route:   route,
+		TripID:  tripID,
+		Hour:    hour,
+		Samples: h.total(),
+		P50:     h.percentile(0.5),
+		P90:     h.percentile(0.9),
+	}
+}
+
+// persisted is the on-disk format of Aggregator
+type persisted struct {
+	Routes []persistedHistogram `json:"routes"`
+	Trips  []persistedHistogram `json:"trips"`
+}
+
+type persistedHistogram struct {
+	Route  string `json:"route"`
+	TripID string `json:"trip_id,omitempty"`
+	Hour   int    `json:"hour"`
+	Counts []int  `json:"counts"` // by occupancy level
+}
+
+// Save writes aggregates to path as JSON
+func (a *Aggregator) Save(path string) error {
+	a.mu.RLock()
+	p := persisted{}
+	for key, h := range a.routes {
+		p.Routes = append(p.Routes, persistedHistogram{Route: key.Route, Hour: key.Hour, Counts: h[:]})
+	}
+	for tripID, h := range a.trips {
+		p.Trips = append(p.Trips, persistedHistogram{Route: a.tripRoutes[tripID], TripID: tripID, Hour: -1, Counts: h[:]})
+	}
+	b, err := json.Marshal(p)
+	a.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("marshal: %v", err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create directory: %v", err)
+	}
+	// Save is called periodically over weeks of samples: replace the file by rename,
+	// so a crash mid-write leaves the previous aggregates for Load instead of invalid JSON
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("write: %v", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename: %v", err)
+	}
+	return nil
+}
+
+// Load merges aggregates saved by Save into a, missing file is not an error
+func (a *Aggregator) Load(path string) error {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read: %v", err)
+	}
+
+	p := persisted{}
+	if err = json.Unmarshal(b, &p); err != nil {
+		return fmt.Errorf("unmarshal: %v", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, ph := range p.Routes {
+		key := routeKey{Route: ph.Route, Hour: ph.Hour}
+		if a.routes[key] == nil {
+			a.routes[key] = &histogram{}
+		}
+		a.routes[key].merge(ph.Counts)
+	}
+	for _, ph := range p.Trips {
+		if a.trips[ph.TripID] == nil {
+			a.trips[ph.TripID] = &histogram{}
+		}
+		a.trips[ph.TripID].merge(ph.Counts)
+		a.tripRoutes[ph.TripID] = ph.Route
+	}
+	return nil
+}
+
+func (h *histogram) merge(counts []int) {
+	for level := 0; level < len(counts) && level < levels; level++ {
+		h[level] += counts[level]
+	}
+}
@@ -0,0 +1,56 @@
+package crowding
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	njt "github.com/errornil/njtransit/v2"
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAggregator(t *testing.T) {
+	a := NewAggregator(time.UTC)
+	morning := time.Date(2024, 10, 1, 8, 15, 0, 0, time.UTC)
+
+	for _, load := range []string{"LIGHT", "LIGHT", "MEDIUM", "MEDIUM", "MEDIUM", "HEAVY", "HEAVY", "HEAVY", "HEAVY", "FULL", ""} {
+		a.AddVehicleLocations(&njt.GetVehicleLocations{{VehicleRoute: "13", VehicleInternalTripNumber: "19624200", VehiclePassengerLoad: load}}, morning)
+	}
+	a.AddFeed(&gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{Timestamp: proto.Uint64(uint64(morning.Add(2 * time.Hour).Unix()))},
+		Entity: []*gtfs.FeedEntity{{
+			Id: proto.String("1"),
+			Vehicle: &gtfs.VehiclePosition{
+				Trip:            &gtfs.TripDescriptor{RouteId: proto.String("13"), TripId: proto.String("T1")},
+				OccupancyStatus: gtfs.VehiclePosition_EMPTY.Enum(),
+			},
+		}},
+	})
+
+	expected := Stats{Route: "13", Hour: 8, Samples: 10, P50: gtfs.VehiclePosition_STANDING_ROOM_ONLY, P90: gtfs.VehiclePosition_FULL}
+	stats, ok := a.Route("13", 8)
+	assert.True(t, ok)
+	assert.Equal(t, expected, stats)
+
+	trip, ok := a.Trip("19624200")
+	assert.True(t, ok)
+	assert.Equal(t, 10, trip.Samples)
+	assert.Equal(t, "13", trip.Route)
+
+	assert.Len(t, a.Routes(), 2)
+	_, ok = a.Route("13", 9)
+	assert.False(t, ok)
+
+	path := filepath.Join(t.TempDir(), "crowding", "aggregates.json")
+	assert.NoError(t, a.Save(path))
+
+	loaded := NewAggregator(time.UTC)
+	assert.NoError(t, loaded.Load(path))
+	assert.Equal(t, a.Routes(), loaded.Routes())
+	stats, _ = loaded.Route("13", 8)
+	assert.Equal(t, expected, stats)
+
+	assert.NoError(t, NewAggregator(nil).Load(filepath.Join(t.TempDir(), "missing.json")))
+}
@@ -0,0 +1,382 @@
+package eta
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	"github.com/errornil/njtransit/v2/mapmatch"
+	gtfs "github.com/errornil/transit_realtime"
+)
+
+// MaxPositionAge is the age after which vehicle position is ignored
+const MaxPositionAge = 10 * time.Minute
+
+// Arrival is a predicted arrival of a vehicle at a stop
+type Arrival struct {
+	TripID         string
+	RouteID        string
+	VehicleID      string
+	StopID         string
+	StopSequence   int
+	ScheduledTime  time.Time
+	PredictedTime  time.Time
+	Delay          time.Duration // PredictedTime - ScheduledTime
+	DistanceMeters float64       // remaining distance along the shape
+	Confidence     float64       // 0..1, see confidence
+}
+
+// vehicle is the latest known position of a vehicle serving a trip
+type vehicle struct {
+	tripID    string
+	vehicleID string
+	startDate string // YYYYMMDD, can be empty
+	lat, lon  float64
+	timestamp time.Time
+}
+
+// timedStop is a trip stop with its position along the shape and scheduled times,
+// times of stops without arrival_time/departure_time are interpolated by distance
+type timedStop struct {
+	stopID    string
+	sequence  int
+	distance  float64
+	arrival   time.Duration
+	departure time.Duration
+}
+
+// Estimator predicts arrival times from vehicle positions,
+// using scheduled running times between stops adjusted by vehicle lateness.
+// Estimator is safe for concurrent use.
+type Estimator struct {
+	matcher     *mapmatch.Matcher
+	location    *time.Location
+	trips       map[string]*gtfsstatic.Trip
+	stopTimes   map[string][]gtfsstatic.StopTime
+	tripsByStop map[string][]string
+
+	mu       sync.RWMutex
+	vehicles map[string]vehicle // by trip_id
+	profiles map[string][]timedStop
+}
+
+// NewEstimator creates new Estimator from parsed GTFS static feed,
+// location is the agency timezone used for service days (America/New_York for NJ TRANSIT)
+func NewEstimator(feed *gtfsstatic.Feed, location *time.Location) *Estimator {
+	e := &Estimator{
+		matcher:     mapmatch.NewMatcher(feed, 0),
+		location:    location,
+		trips:       feed.TripByID(),
+		stopTimes:   feed.StopTimesByTrip(),
+		tripsByStop: map[string][]string{},
+		vehicles:    map[string]vehicle{},
+		profiles:    map[string][]timedStop{},
+	}
+
+	for tripID, stopTimes := range e.stopTimes {
+		// loop trips visit the same stop more than once, predict returns every visit
+		seen := map[string]bool{}
+		for _, st := range stopTimes {
+			if seen[st.StopID] {
+				continue
+			}
+			seen[st.StopID] = true
+			e.tripsByStop[st.StopID] = append(e.tripsByStop[st.StopID], tripID)
+		}
+	}
+
+	return e
+}
+
+// Update stores the latest vehicle positions from GTFS-RT feed (BusClient.GetVehiclePositions).
+// Older positions than already known for the same trip are ignored,
+// so recorded feeds can be replayed in any order.
+func (e *Estimator) Update(feed *gtfs.FeedMessage) {
+	headerTimestamp := feed.GetHeader().GetTimestamp()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, entity := range feed.GetEntity() {
+		v := entity.GetVehicle()
+		tripID := v.GetTrip().GetTripId()
+		if v == nil || v.GetPosition() == nil || tripID == "" || entity.GetIsDeleted() {
+			continue
+		}
+
+		timestamp := v.GetTimestamp()
+		if timestamp == 0 {
+			timestamp = headerTimestamp
+		}
+
+		next := vehicle{
+			tripID:    tripID,
+			vehicleID: v.GetVehicle().GetId(),
+			startDate: v.GetTrip().GetStartDate(),
+			lat:       float64(v.GetPosition().GetLatitude()),
+			lon:       float64(v.GetPosition().GetLongitude()),
+			timestamp: time.Unix(int64(timestamp), 0),
+		}
+
+		if current, ok := e.vehicles[tripID]; ok && current.timestamp.After(next.timestamp) {
+			continue
+		}
+		e.vehicles[tripID] = next
+	}
+}
+
+// Arrivals returns predicted arrivals at the stop ordered by predicted time.
+// Vehicles that already passed the stop or reported more than MaxPositionAge before now are skipped.
+func (e *Estimator) Arrivals(stopID string, now time.Time) ([]Arrival, error) {
+	tripIDs, ok := e.tripsByStop[stopID]
+	if !ok {
+		return nil, fmt.Errorf("unknown stop %q", stopID)
+	}
+
+	e.mu.RLock()
+	var vehicles []vehicle
+	for _, tripID := range tripIDs {
+		if v, ok := e.vehicles[tripID]; ok && now.Sub(v.timestamp) <= MaxPositionAge {
+			vehicles = append(vehicles, v)
+		}
+	}
+	e.mu.RUnlock()
+
+	var arrivals []Arrival
+	for _, v := range vehicles {
+		tripArrivals, err := e.predict(v, now)
+		if err != nil {
+			continue
+		}
+		for _, a := range tripArrivals {
+			if a.StopID == stopID {
+				arrivals = append(arrivals, a)
+			}
+		}
+	}
+
+	sort.Slice(arrivals, func(i, j int) bool {
+		return arrivals[i].PredictedTime.Before(arrivals[j].PredictedTime)
+	})
+	return arrivals, nil
+}
+
+// TripArrivals returns predicted arrivals for all remaining stops of the trip
+func (e *Estimator) TripArrivals(tripID string, now time.Time) ([]Arrival, error) {
+	e.mu.RLock()
+	v, ok := e.vehicles[tripID]
+	e.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no vehicle for trip %q", tripID)
+	}
+
+	return e.predict(v, now)
+}
+
+func (e *Estimator) predict(v vehicle, now time.Time) ([]Arrival, error) {
+	progress, err := e.matcher.Match(v.tripID, v.lat, v.lon)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := e.profile(v.tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	// scheduled time at the vehicle position
+	scheduled := scheduledAt(profile, progress.DistanceTraveledMeters)
+	serviceDay := e.serviceDay(v, scheduled)
+	lateness := v.timestamp.Sub(serviceDay.Add(scheduled))
+	if progress.DistanceTraveledMeters <= profile[0].distance && lateness < 0 {
+		// vehicle waits at the first stop, it will not depart early
+		lateness = 0
+	}
+
+	var routeID string
+	if trip, ok := e.trips[v.tripID]; ok {
+		routeID = trip.RouteID
+	}
+
+	var arrivals []Arrival
+	for _, stop := range profile {
+		if stop.distance < progress.DistanceTraveledMeters {
+			continue
+		}
+
+		scheduledTime := serviceDay.Add(stop.arrival)
+		remaining := stop.distance - progress.DistanceTraveledMeters
+		arrivals = append(arrivals, Arrival{
+			TripID:         v.tripID,
+			RouteID:        routeID,
+			VehicleID:      v.vehicleID,
+			StopID:         stop.stopID,
+			StopSequence:   stop.sequence,
+			ScheduledTime:  scheduledTime,
+			PredictedTime:  scheduledTime.Add(lateness),
+			Delay:          lateness,
+			DistanceMeters: remaining,
+			Confidence:     confidence(now.Sub(v.timestamp), stop.arrival-scheduled, progress.OffRoute),
+		})
+	}
+	return arrivals, nil
+}
+
+// profile builds trip stops with distances and scheduled times
+func (e *Estimator) profile(tripID string) ([]timedStop, error) {
+	e.mu.RLock()
+	profile, ok := e.profiles[tripID]
+	e.mu.RUnlock()
+	if ok {
+		return profile, nil
+	}
+
+	stops, err := e.matcher.TripStops(tripID)
+	if err != nil {
+		return nil, err
+	}
+	distances := make(map[int]float64, len(stops))
+	for _, s := range stops {
+		distances[s.StopSequence] = s.DistanceMeters
+	}
+
+	for _, st := range e.stopTimes[tripID] {
+		d, ok := distances[st.StopSequence]
+		if !ok {
+			continue
+		}
+		arrival, departure := st.ArrivalTime, st.DepartureTime
+		if arrival == gtfsstatic.NoTime {
+			arrival = departure
+		}
+		if departure == gtfsstatic.NoTime {
+			departure = arrival
+		}
+		profile = append(profile, timedStop{
+			stopID:    st.StopID,
+			sequence:  st.StopSequence,
+			distance:  d,
+			arrival:   arrival,
+			departure: departure,
+		})
+	}
+
+	if !interpolate(profile) {
+		return nil, fmt.Errorf("trip %q has no scheduled times", tripID)
+	}
+
+	e.mu.Lock()
+	e.profiles[tripID] = profile
+	e.mu.Unlock()
+	return profile, nil
+}
+
+// serviceDay returns midnight (noon minus 12h) of the trip service day:
+// from vehicle trip start_date if present, otherwise the day when
+// the scheduled time at vehicle position is the closest to vehicle timestamp
+func (e *Estimator) serviceDay(v vehicle, scheduled time.Duration) time.Time {
+	if v.startDate != "" {
+		if d, err := time.ParseInLocation("20060102", v.startDate, e.location); err == nil {
+			return noonMinus12h(d, e.location)
+		}
+	}
+
+	local := v.timestamp.In(e.location)
+	best := noonMinus12h(local, e.location)
+	for _, days := range []int{-1, 1} {
+		day := noonMinus12h(local.AddDate(0, 0, days), e.location)
+		if absDuration(v.timestamp.Sub(day.Add(scheduled))) < absDuration(v.timestamp.Sub(best.Add(scheduled))) {
+			best = day
+		}
+	}
+	return best
+}
+
+// scheduledAt returns scheduled time at distance along the shape,
+// interpolated between departure from the previous stop and arrival at the next one
+func scheduledAt(profile []timedStop, distance float64) time.Duration {
+	if distance <= profile[0].distance {
+		return profile[0].departure
+	}
+	for i := 1; i < len(profile); i++ {
+		prev, next := profile[i-1], profile[i]
+		if distance > next.distance {
+			continue
+		}
+		if next.distance == prev.distance {
+			return next.arrival
+		}
+		fraction := (distance - prev.distance) / (next.distance - prev.distance)
+		return prev.departure + time.Duration(fraction*float64(next.arrival-prev.departure))
+	}
+	return profile[len(profile)-1].arrival
+}
+
+// interpolate fills times of stops without scheduled times by distance
+// between surrounding timed stops, returns false if no stop has times
+func interpolate(profile []timedStop) bool {
+	prev := -1
+	for i := range profile {
+		if profile[i].arrival == gtfsstatic.NoTime {
+			continue
+		}
+		if prev == -1 {
+			// stops before the first timed stop get its time
+			for j := 0; j < i; j++ {
+				profile[j].arrival = profile[i].arrival
+				profile[j].departure = profile[i].arrival
+			}
+		} else {
+			from, to := profile[prev], profile[i]
+			for j := prev + 1; j < i; j++ {
+				fraction := 0.0
+				if to.distance > from.distance {
+					fraction = (profile[j].distance - from.distance) / (to.distance - from.distance)
+				}
+				t := from.departure + time.Duration(fraction*float64(to.arrival-from.departure))
+				profile[j].arrival = t
+				profile[j].departure = t
+			}
+		}
+		prev = i
+	}
+	if prev == -1 {
+		return false
+	}
+	for j := prev + 1; j < len(profile); j++ {
+		profile[j].arrival = profile[prev].departure
+		profile[j].departure = profile[prev].departure
+	}
+	return true
+}
+
+// confidence decreases with position age and prediction horizon,
+// and drops when the vehicle is off its route
+func confidence(age, horizon time.Duration, offRoute bool) float64 {
+	if age < 0 {
+		age = 0
+	}
+	if horizon < 0 {
+		horizon = 0
+	}
+
+	c := math.Exp(-age.Seconds()/300) / (1 + horizon.Seconds()/1800)
+	if offRoute {
+		c *= 0.3
+	}
+	return math.Round(c*100) / 100
+}
+
+func noonMinus12h(t time.Time, location *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, location).Add(-12 * time.Hour)
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,144 @@
+package eta
+
+import (
+	"testing"
+	"time"
+
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func testFeed() *gtfsstatic.Feed {
+	return &gtfsstatic.Feed{
+		Stops: []gtfsstatic.Stop{
+			{ID: "A", Lat: 40.7, Lon: -74.2},
+			{ID: "B", Lat: 40.7, Lon: -74.15},
+			{ID: "C", Lat: 40.7, Lon: -74.1},
+		},
+		Trips: []gtfsstatic.Trip{
+			{ID: "T1", RouteID: "10", ShapeID: "S1"},
+		},
+		StopTimes: []gtfsstatic.StopTime{
+			{TripID: "T1", StopID: "A", StopSequence: 1, ArrivalTime: 8 * time.Hour, DepartureTime: 8 * time.Hour},
+			{TripID: "T1", StopID: "B", StopSequence: 2, ArrivalTime: gtfsstatic.NoTime, DepartureTime: gtfsstatic.NoTime},
+			{TripID: "T1", StopID: "C", StopSequence: 3, ArrivalTime: 8*time.Hour + 20*time.Minute, DepartureTime: 8*time.Hour + 20*time.Minute},
+		},
+		Shapes: []gtfsstatic.Shape{
+			{
+				ID: "S1",
+				Points: []gtfsstatic.ShapePoint{
+					{Lat: 40.7, Lon: -74.2, Sequence: 1},
+					{Lat: 40.7, Lon: -74.1, Sequence: 2},
+				},
+			},
+		},
+	}
+}
+
+// recordedFeed is a VehiclePositions feed as returned by BusClient.GetVehiclePositions
+func recordedFeed(timestamp time.Time, lon float32) *gtfs.FeedMessage {
+	return &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{
+			GtfsRealtimeVersion: proto.String("2.0"),
+			Timestamp:           proto.Uint64(uint64(timestamp.Unix())),
+		},
+		Entity: []*gtfs.FeedEntity{
+			{
+				Id: proto.String("1"),
+				Vehicle: &gtfs.VehiclePosition{
+					Trip:     &gtfs.TripDescriptor{TripId: proto.String("T1")},
+					Vehicle:  &gtfs.VehicleDescriptor{Id: proto.String("5987")},
+					Position: &gtfs.Position{Latitude: proto.Float32(40.7), Longitude: proto.Float32(lon)},
+				},
+			},
+		},
+	}
+}
+
+func TestArrivals(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data is not available: %v", err)
+	}
+
+	e := NewEstimator(testFeed(), location)
+
+	// vehicle is at 1/4 of the trip at 08:07, scheduled at 08:05, so it is 2 minutes late
+	observed := time.Date(2024, 9, 30, 8, 7, 0, 0, location)
+	e.Update(recordedFeed(observed, -74.175))
+	// older snapshot must not override the latest position
+	e.Update(recordedFeed(observed.Add(-time.Minute), -74.2))
+
+	arrivals, err := e.Arrivals("C", observed)
+	assert.NoError(t, err)
+	if assert.Len(t, arrivals, 1) {
+		a := arrivals[0]
+		assert.Equal(t, "T1", a.TripID)
+		assert.Equal(t, "10", a.RouteID)
+		assert.Equal(t, "5987", a.VehicleID)
+		assert.Equal(t, time.Date(2024, 9, 30, 8, 20, 0, 0, location), a.ScheduledTime)
+		assert.WithinDuration(t, time.Date(2024, 9, 30, 8, 22, 0, 0, location), a.PredictedTime, time.Second)
+		assert.InDelta(t, 2*time.Minute, a.Delay, float64(time.Second))
+		assert.True(t, a.Confidence > 0 && a.Confidence < 1)
+	}
+
+	// B has no scheduled times, it is interpolated to 08:10
+	arrivals, err = e.Arrivals("B", observed)
+	assert.NoError(t, err)
+	if assert.Len(t, arrivals, 1) {
+		assert.Equal(t, time.Date(2024, 9, 30, 8, 10, 0, 0, location), arrivals[0].ScheduledTime)
+	}
+
+	// vehicle already passed A
+	arrivals, err = e.Arrivals("A", observed)
+	assert.NoError(t, err)
+	assert.Empty(t, arrivals)
+
+	// position is too old
+	arrivals, err = e.Arrivals("C", observed.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, arrivals)
+
+	_, err = e.Arrivals("unknown", observed)
+	assert.Error(t, err)
+}
+
+func TestArrivalsLoopTrip(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data is not available: %v", err)
+	}
+
+	// T1 goes east through B, turns north at D and comes back to B
+	feed := testFeed()
+	feed.Stops = append(feed.Stops, gtfsstatic.Stop{ID: "D", Lat: 40.705, Lon: -74.1})
+	feed.StopTimes = []gtfsstatic.StopTime{
+		{TripID: "T1", StopID: "A", StopSequence: 1, ArrivalTime: 8 * time.Hour, DepartureTime: 8 * time.Hour},
+		{TripID: "T1", StopID: "B", StopSequence: 2, ArrivalTime: 8*time.Hour + 10*time.Minute, DepartureTime: 8*time.Hour + 10*time.Minute},
+		{TripID: "T1", StopID: "D", StopSequence: 3, ArrivalTime: 8*time.Hour + 20*time.Minute, DepartureTime: 8*time.Hour + 20*time.Minute},
+		{TripID: "T1", StopID: "B", StopSequence: 4, ArrivalTime: 8*time.Hour + 30*time.Minute, DepartureTime: 8*time.Hour + 30*time.Minute},
+	}
+	feed.Shapes[0].Points = []gtfsstatic.ShapePoint{
+		{Lat: 40.7, Lon: -74.2, Sequence: 1},
+		{Lat: 40.7, Lon: -74.1, Sequence: 2},
+		{Lat: 40.71, Lon: -74.1, Sequence: 3},
+		{Lat: 40.71, Lon: -74.15, Sequence: 4},
+		{Lat: 40.7, Lon: -74.15, Sequence: 5},
+	}
+	e := NewEstimator(feed, location)
+
+	observed := time.Date(2024, 9, 30, 8, 7, 0, 0, location)
+	e.Update(recordedFeed(observed, -74.175))
+
+	// each visit is reported once with its own scheduled time
+	arrivals, err := e.Arrivals("B", observed)
+	assert.NoError(t, err)
+	if assert.Len(t, arrivals, 2) {
+		assert.Equal(t, 2, arrivals[0].StopSequence)
+		assert.Equal(t, time.Date(2024, 9, 30, 8, 10, 0, 0, location), arrivals[0].ScheduledTime)
+		assert.Equal(t, 4, arrivals[1].StopSequence)
+		assert.Equal(t, time.Date(2024, 9, 30, 8, 30, 0, 0, location), arrivals[1].ScheduledTime)
+	}
+}
@@ -0,0 +1,414 @@
+package gtfsdiff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	"github.com/errornil/njtransit/v2/spatial"
+)
+
+// DefaultMoveThresholdMeters is the distance after which stop is reported as moved
+const DefaultMoveThresholdMeters = 50
+
+// Diff is a result of comparing two GTFS static feeds
+type Diff struct {
+	OldVersion string `json:"old_version,omitempty"`
+	NewVersion string `json:"new_version,omitempty"`
+
+	Stops  EntityDiff `json:"stops"`
+	Routes EntityDiff `json:"routes"`
+	Trips  EntityDiff `json:"trips"`
+	Shapes EntityDiff `json:"shapes"`
+
+	Services   []ServiceChange `json:"services,omitempty"`
+	MovedStops []StopMove      `json:"moved_stops,omitempty"`
+}
+
+// EntityDiff lists changes of one entity type
+type EntityDiff struct {
+	Added     []Entity   `json:"added,omitempty"`
+	Removed   []Entity   `json:"removed,omitempty"`
+	Renamed   []Rename   `json:"renamed,omitempty"`    // same ID, different name
+	Modified  []Entity   `json:"modified,omitempty"`   // same ID and name, different content
+	IDChanged []IDChange `json:"id_changed,omitempty"` // removed and added entities that look the same
+}
+
+// Entity is a stop, route, trip or shape
+type Entity struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// Rename is an entity that kept its ID but changed its name
+type Rename struct {
+	ID      string `json:"id"`
+	OldName string `json:"old_name"`
+	NewName string `json:"new_name"`
+}
+
+// IDChange is an entity that was removed and added back with different ID,
+// matched by stop_code, route_short_name or shape geometry
+type IDChange struct {
+	OldID string `json:"old_id"`
+	NewID string `json:"new_id"`
+	Name  string `json:"name,omitempty"`
+}
+
+// ServiceChange is a change of service calendar
+type ServiceChange struct {
+	ServiceID string `json:"service_id"`
+	Change    string `json:"change"` // added, removed or changed
+	Details   string `json:"details,omitempty"`
+}
+
+// StopMove is a stop which coordinates changed more than threshold
+type StopMove struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name,omitempty"`
+	OldLat         float64 `json:"old_lat"`
+	OldLon         float64 `json:"old_lon"`
+	NewLat         float64 `json:"new_lat"`
+	NewLon         float64 `json:"new_lon"`
+	DistanceMeters float64 `json:"distance_meters"`
+}
+
+// entry is an entity prepared for comparison
+type entry struct {
+	name      string
+	label     string // shown instead of empty name, not compared
+	identity  string // key to match entities with changed IDs, can be empty
+	signature string // content to detect modifications
+}
+
+// Compare compares two parsed GTFS static feeds,
+// moveThreshold is in meters, DefaultMoveThresholdMeters is used when it's not positive
+func Compare(oldFeed, newFeed *gtfsstatic.Feed, moveThreshold float64) *Diff {
+	if moveThreshold <= 0 {
+		moveThreshold = DefaultMoveThresholdMeters
+	}
+
+	d := &Diff{
+		Stops:    compareEntries(stopEntries(oldFeed), stopEntries(newFeed)),
+		Routes:   compareEntries(routeEntries(oldFeed), routeEntries(newFeed)),
+		Trips:    compareEntries(tripEntries(oldFeed), tripEntries(newFeed)),
+		Shapes:   compareEntries(shapeEntries(oldFeed), shapeEntries(newFeed)),
+		Services: compareServices(oldFeed, newFeed),
+	}
+	if oldFeed.FeedInfo != nil {
+		d.OldVersion = oldFeed.FeedInfo.Version
+	}
+	if newFeed.FeedInfo != nil {
+		d.NewVersion = newFeed.FeedInfo.Version
+	}
+
+	newStops := newFeed.StopByID()
+	for _, oldStop := range oldFeed.Stops {
+		newStop, ok := newStops[oldStop.ID]
+		if !ok {
+			continue
+		}
+		distance := spatial.Distance(oldStop.Lat, oldStop.Lon, newStop.Lat, newStop.Lon)
+		if distance > moveThreshold {
+			d.MovedStops = append(d.MovedStops, StopMove{
+				ID:             oldStop.ID,
+				Name:           newStop.Name,
+				OldLat:         oldStop.Lat,
+				OldLon:         oldStop.Lon,
+				NewLat:         newStop.Lat,
+				NewLon:         newStop.Lon,
+				DistanceMeters: distance,
+			})
+		}
+	}
+	sort.Slice(d.MovedStops, func(i, j int) bool {
+		return d.MovedStops[i].ID < d.MovedStops[j].ID
+	})
+
+	return d
+}
+
+// Empty returns true if feeds have no differences
+func (d *Diff) Empty() bool {
+	return d.Stops.empty() && d.Routes.empty() && d.Trips.empty() && d.Shapes.empty() &&
+		len(d.Services) == 0 && len(d.MovedStops) == 0
+}
+
+// WriteText writes human-readable diff
+func (d *Diff) WriteText(w io.Writer) error {
+	b := &strings.Builder{}
+
+	if d.OldVersion != "" || d.NewVersion != "" {
+		fmt.Fprintf(b, "Feed version: %s → %s\n", d.OldVersion, d.NewVersion)
+	}
+	if d.Empty() {
+		b.WriteString("No changes\n")
+	}
+
+	d.Stops.writeText(b, "Stops")
+	d.Routes.writeText(b, "Routes")
+	d.Trips.writeText(b, "Trips")
+	d.Shapes.writeText(b, "Shapes")
+
+	if len(d.MovedStops) > 0 {
+		fmt.Fprintf(b, "\nMoved stops (%d):\n", len(d.MovedStops))
+		for _, m := range d.MovedStops {
+			fmt.Fprintf(b, "  ~ %s %s: %.0fm (%.6f,%.6f → %.6f,%.6f)\n",
+				m.ID, m.Name, m.DistanceMeters, m.OldLat, m.OldLon, m.NewLat, m.NewLon)
+		}
+	}
+
+	if len(d.Services) > 0 {
+		fmt.Fprintf(b, "\nService calendars (%d):\n", len(d.Services))
+		for _, s := range d.Services {
+			fmt.Fprintf(b, "  %s %s", s.Change, s.ServiceID)
+			if s.Details != "" {
+				fmt.Fprintf(b, ": %s", s.Details)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (e EntityDiff) empty() bool {
+	return len(e.Added) == 0 && len(e.Removed) == 0 && len(e.Renamed) == 0 &&
+		len(e.Modified) == 0 && len(e.IDChanged) == 0
+}
+
+func (e EntityDiff) writeText(b *strings.Builder, title string) {
+	if e.empty() {
+		return
+	}
+
+	fmt.Fprintf(
+		b,
+		"\n%s: %d added, %d removed, %d renamed, %d modified, %d changed ID\n",
+		title, len(e.Added), len(e.Removed), len(e.Renamed), len(e.Modified), len(e.IDChanged),
+	)
+	for _, entity := range e.Added {
+		fmt.Fprintf(b, "  + %s %s\n", entity.ID, entity.Name)
+	}
+	for _, entity := range e.Removed {
+		fmt.Fprintf(b, "  - %s %s\n", entity.ID, entity.Name)
+	}
+	for _, r := range e.Renamed {
+		fmt.Fprintf(b, "  ~ %s %q → %q\n", r.ID, r.OldName, r.NewName)
+	}
+	for _, entity := range e.Modified {
+		fmt.Fprintf(b, "  * %s %s\n", entity.ID, entity.Name)
+	}
+	for _, c := range e.IDChanged {
+		fmt.Fprintf(b, "  # %s → %s %s\n", c.OldID, c.NewID, c.Name)
+	}
+}
+
+func compareEntries(oldEntries, newEntries map[string]entry) EntityDiff {
+	d := EntityDiff{}
+
+	removed := map[string][]string{} // identity to IDs, sorted
+	for _, id := range sortedIDs(oldEntries) {
+		o := oldEntries[id]
+		n, ok := newEntries[id]
+		if !ok {
+			if o.identity != "" {
+				removed[o.identity] = append(removed[o.identity], id)
+			}
+			continue
+		}
+		if o.name != n.name {
+			d.Renamed = append(d.Renamed, Rename{ID: id, OldName: o.name, NewName: n.name})
+		} else if o.signature != n.signature {
+			d.Modified = append(d.Modified, n.entity(id))
+		}
+	}
+
+	matched := map[string]bool{} // old IDs matched by identity
+	for _, id := range sortedIDs(newEntries) {
+		n := newEntries[id]
+		if _, ok := oldEntries[id]; ok {
+			continue
+		}
+		// entities with the same identity are matched in ID order
+		if oldIDs := removed[n.identity]; n.identity != "" && len(oldIDs) > 0 {
+			removed[n.identity] = oldIDs[1:]
+			matched[oldIDs[0]] = true
+			d.IDChanged = append(d.IDChanged, IDChange{OldID: oldIDs[0], NewID: id, Name: n.entity(id).Name})
+			continue
+		}
+		d.Added = append(d.Added, n.entity(id))
+	}
+
+	for _, id := range sortedIDs(oldEntries) {
+		if _, ok := newEntries[id]; ok || matched[id] {
+			continue
+		}
+		d.Removed = append(d.Removed, oldEntries[id].entity(id))
+	}
+
+	sortEntities(d.Added)
+	sortEntities(d.Removed)
+	sortEntities(d.Modified)
+	sort.Slice(d.Renamed, func(i, j int) bool { return d.Renamed[i].ID < d.Renamed[j].ID })
+	sort.Slice(d.IDChanged, func(i, j int) bool { return d.IDChanged[i].OldID < d.IDChanged[j].OldID })
+	return d
+}
+
+// entity returns Entity with name of e, or its label if name is empty
+func (e entry) entity(id string) Entity {
+	if e.name == "" {
+		return Entity{ID: id, Name: e.label}
+	}
+	return Entity{ID: id, Name: e.name}
+}
+
+func sortedIDs(entries map[string]entry) []string {
+	ids := make([]string, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func stopEntries(feed *gtfsstatic.Feed) map[string]entry {
+	m := make(map[string]entry, len(feed.Stops))
+	for _, s := range feed.Stops {
+		identity := ""
+		if s.Code != "" {
+			identity = "code:" + s.Code
+		} else {
+			// ~10m precision
+			identity = fmt.Sprintf("%s@%.4f,%.4f", s.Name, s.Lat, s.Lon)
+		}
+		m[s.ID] = entry{
+			name:      s.Name,
+			identity:  identity,
+			signature: fmt.Sprintf("%s|%s|%s|%d|%s", s.Code, s.Desc, s.ZoneID, s.LocationType, s.ParentStation),
+		}
+	}
+	return m
+}
+
+func routeEntries(feed *gtfsstatic.Feed) map[string]entry {
+	m := make(map[string]entry, len(feed.Routes))
+	for _, r := range feed.Routes {
+		name := strings.TrimSpace(r.ShortName + " " + r.LongName)
+		identity := ""
+		if r.ShortName != "" {
+			identity = r.AgencyID + "|" + r.ShortName
+		}
+		m[r.ID] = entry{
+			name:      name,
+			identity:  identity,
+			signature: fmt.Sprintf("%s|%d|%s", r.AgencyID, r.Type, r.Color),
+		}
+	}
+	return m
+}
+
+func tripEntries(feed *gtfsstatic.Feed) map[string]entry {
+	m := make(map[string]entry, len(feed.Trips))
+	for _, t := range feed.Trips {
+		m[t.ID] = entry{
+			name:      t.Headsign,
+			signature: fmt.Sprintf("%s|%s|%d|%s|%s", t.RouteID, t.ServiceID, t.DirectionID, t.BlockID, t.ShapeID),
+		}
+	}
+	return m
+}
+
+func shapeEntries(feed *gtfsstatic.Feed) map[string]entry {
+	m := make(map[string]entry, len(feed.Shapes))
+	for _, s := range feed.Shapes {
+		b := &strings.Builder{}
+		for _, p := range s.Points {
+			fmt.Fprintf(b, "%.5f,%.5f;", p.Lat, p.Lon)
+		}
+		// shapes have no name, geometry is their content
+		m[s.ID] = entry{
+			label:     fmt.Sprintf("(%d points)", len(s.Points)),
+			identity:  b.String(),
+			signature: b.String(),
+		}
+	}
+	return m
+}
+
+func compareServices(oldFeed, newFeed *gtfsstatic.Feed) []ServiceChange {
+	oldServices := services(oldFeed)
+	newServices := services(newFeed)
+
+	var changes []ServiceChange
+	for id, o := range oldServices {
+		n, ok := newServices[id]
+		if !ok {
+			changes = append(changes, ServiceChange{ServiceID: id, Change: "removed", Details: o})
+			continue
+		}
+		if o != n {
+			changes = append(changes, ServiceChange{ServiceID: id, Change: "changed", Details: o + " → " + n})
+		}
+	}
+	for id, n := range newServices {
+		if _, ok := oldServices[id]; !ok {
+			changes = append(changes, ServiceChange{ServiceID: id, Change: "added", Details: n})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].ServiceID < changes[j].ServiceID
+	})
+	return changes
+}
+
+// services describes each service_id from calendar.txt and calendar_dates.txt
+func services(feed *gtfsstatic.Feed) map[string]string {
+	weekdays := []time.Weekday{
+		time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+	}
+
+	m := map[string]string{}
+	for _, c := range feed.Calendars {
+		days := make([]byte, 0, len(weekdays))
+		for _, day := range weekdays {
+			if c.Weekdays[day] {
+				days = append(days, day.String()[0])
+			} else {
+				days = append(days, '-')
+			}
+		}
+		m[c.ServiceID] = fmt.Sprintf("%s %s-%s", days, c.StartDate, c.EndDate)
+	}
+
+	added := map[string][]string{}
+	removed := map[string][]string{}
+	for _, cd := range feed.CalendarDates {
+		switch cd.ExceptionType {
+		case 1:
+			added[cd.ServiceID] = append(added[cd.ServiceID], cd.Date)
+		case 2:
+			removed[cd.ServiceID] = append(removed[cd.ServiceID], cd.Date)
+		}
+	}
+	for id, dates := range added {
+		sort.Strings(dates)
+		m[id] = strings.TrimSpace(m[id] + " +" + strings.Join(dates, ",+"))
+	}
+	for id, dates := range removed {
+		sort.Strings(dates)
+		m[id] = strings.TrimSpace(m[id] + " -" + strings.Join(dates, ",-"))
+	}
+	return m
+}
+
+func sortEntities(entities []Entity) {
+	sort.Slice(entities, func(i, j int) bool {
+		return entities[i].ID < entities[j].ID
+	})
+}
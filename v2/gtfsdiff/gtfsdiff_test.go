@@ -0,0 +1,114 @@
+package gtfsdiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	oldFeed := &gtfsstatic.Feed{
+		Stops: []gtfsstatic.Stop{
+			{ID: "1", Code: "20001", Name: "MAIN ST AT 1ST AVE", Lat: 40.7, Lon: -74.1},
+			{ID: "2", Code: "20002", Name: "MAIN ST AT 2ND AVE", Lat: 40.71, Lon: -74.1},
+			{ID: "3", Code: "20003", Name: "MAIN ST AT 3RD AVE", Lat: 40.72, Lon: -74.1},
+			{ID: "4", Code: "20004", Name: "MAIN ST AT 4TH AVE", Lat: 40.73, Lon: -74.1},
+		},
+		Routes: []gtfsstatic.Route{
+			{ID: "10", ShortName: "1", LongName: "Newark"},
+		},
+		Calendars: []gtfsstatic.Calendar{
+			{ServiceID: "WKD", Weekdays: [7]bool{false, true, true, true, true, true, false}, StartDate: "20240101", EndDate: "20240630"},
+		},
+		FeedInfo: &gtfsstatic.FeedInfo{Version: "v1"},
+	}
+	newFeed := &gtfsstatic.Feed{
+		Stops: []gtfsstatic.Stop{
+			{ID: "1", Code: "20001", Name: "MAIN ST AT 1ST AVE", Lat: 40.7, Lon: -74.1},
+			{ID: "2", Code: "20002", Name: "MAIN ST AT SECOND AVE", Lat: 40.71, Lon: -74.1},
+			{ID: "33", Code: "20003", Name: "MAIN ST AT 3RD AVE", Lat: 40.72, Lon: -74.1},
+			{ID: "4", Code: "20004", Name: "MAIN ST AT 4TH AVE", Lat: 40.731, Lon: -74.1},
+			{ID: "5", Code: "20005", Name: "MAIN ST AT 5TH AVE", Lat: 40.74, Lon: -74.1},
+		},
+		Routes: []gtfsstatic.Route{
+			{ID: "10", ShortName: "1", LongName: "Newark"},
+		},
+		Calendars: []gtfsstatic.Calendar{
+			{ServiceID: "WKD", Weekdays: [7]bool{false, true, true, true, true, true, false}, StartDate: "20240701", EndDate: "20241231"},
+		},
+		CalendarDates: []gtfsstatic.CalendarDate{
+			{ServiceID: "HOL", Date: "20240704", ExceptionType: 1},
+		},
+		FeedInfo: &gtfsstatic.FeedInfo{Version: "v2"},
+	}
+
+	d := Compare(oldFeed, newFeed, 0)
+
+	assert.False(t, d.Empty())
+	assert.Equal(t, "v1", d.OldVersion)
+	assert.Equal(t, "v2", d.NewVersion)
+	assert.Equal(t, []Entity{{ID: "5", Name: "MAIN ST AT 5TH AVE"}}, d.Stops.Added)
+	assert.Empty(t, d.Stops.Removed)
+	assert.Equal(t, []Rename{{ID: "2", OldName: "MAIN ST AT 2ND AVE", NewName: "MAIN ST AT SECOND AVE"}}, d.Stops.Renamed)
+	assert.Equal(t, []IDChange{{OldID: "3", NewID: "33", Name: "MAIN ST AT 3RD AVE"}}, d.Stops.IDChanged)
+	if assert.Len(t, d.MovedStops, 1) {
+		assert.Equal(t, "4", d.MovedStops[0].ID)
+		assert.InDelta(t, 111, d.MovedStops[0].DistanceMeters, 1)
+	}
+	assert.True(t, d.Routes.empty())
+	assert.Equal(t, []ServiceChange{
+		{ServiceID: "HOL", Change: "added", Details: "+20240704"},
+		{ServiceID: "WKD", Change: "changed", Details: "MTWTF-- 20240101-20240630 → MTWTF-- 20240701-20241231"},
+	}, d.Services)
+
+	b := &bytes.Buffer{}
+	assert.NoError(t, d.WriteText(b))
+	assert.Contains(t, b.String(), `~ 2 "MAIN ST AT 2ND AVE" → "MAIN ST AT SECOND AVE"`)
+
+	_, err := json.Marshal(d)
+	assert.NoError(t, err)
+
+	assert.True(t, Compare(oldFeed, oldFeed, 0).Empty())
+}
+
+func TestCompareShapes(t *testing.T) {
+	oldFeed := &gtfsstatic.Feed{Shapes: []gtfsstatic.Shape{
+		{ID: "A", Points: []gtfsstatic.ShapePoint{{Lat: 40.7, Lon: -74.1}, {Lat: 40.71, Lon: -74.1}}},
+		{ID: "B", Points: []gtfsstatic.ShapePoint{{Lat: 40.8, Lon: -74.2}, {Lat: 40.81, Lon: -74.2}}},
+	}}
+	newFeed := &gtfsstatic.Feed{Shapes: []gtfsstatic.Shape{
+		{ID: "A", Points: []gtfsstatic.ShapePoint{{Lat: 40.7, Lon: -74.1}, {Lat: 40.71, Lon: -74.1}, {Lat: 40.72, Lon: -74.1}}},
+		{ID: "C", Points: []gtfsstatic.ShapePoint{{Lat: 40.8, Lon: -74.2}, {Lat: 40.81, Lon: -74.2}}},
+	}}
+
+	d := Compare(oldFeed, newFeed, 0)
+	assert.Empty(t, d.Shapes.Renamed)
+	assert.Equal(t, []Entity{{ID: "A", Name: "(3 points)"}}, d.Shapes.Modified)
+	assert.Equal(t, []IDChange{{OldID: "B", NewID: "C", Name: "(2 points)"}}, d.Shapes.IDChanged)
+}
+
+func TestCompareDuplicateIdentities(t *testing.T) {
+	// both stops share stop_code, each removed stop is matched once and in ID order
+	oldFeed := &gtfsstatic.Feed{Stops: []gtfsstatic.Stop{
+		{ID: "1", Code: "20001", Name: "MAIN ST"},
+		{ID: "2", Code: "20001", Name: "MAIN ST"},
+	}}
+	newFeed := &gtfsstatic.Feed{Stops: []gtfsstatic.Stop{
+		{ID: "11", Code: "20001", Name: "MAIN ST"},
+		{ID: "12", Code: "20001", Name: "MAIN ST"},
+		{ID: "13", Code: "20001", Name: "MAIN ST"},
+	}}
+
+	for i := 0; i < 10; i++ {
+		d := Compare(oldFeed, newFeed, 0)
+		assert.Equal(t, []IDChange{
+			{OldID: "1", NewID: "11", Name: "MAIN ST"},
+			{OldID: "2", NewID: "12", Name: "MAIN ST"},
+		}, d.Stops.IDChanged)
+		assert.Equal(t, []Entity{{ID: "13", Name: "MAIN ST"}}, d.Stops.Added)
+		assert.Empty(t, d.Stops.Removed)
+	}
+}
@@ -0,0 +1,197 @@
+package gtfsrt
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+	gtfs "github.com/errornil/transit_realtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// Time formats used by BusDataClient responses
+const (
+	busDataTimestampLayout    = "02-Jan-2006 03:04:05 PM"      // 25-Apr-2019 12:15:12 AM
+	busDataSchedDepTimeLayout = "02-Jan-06 03.04.05.000000 PM" // 03-JAN-19 01.41.00.000000 AM
+)
+
+// BusDataConverter converts legacy BusDataClient responses into GTFS-Realtime feeds
+type BusDataConverter struct {
+	location *time.Location
+	routeIDs map[string]string
+}
+
+// NewBusDataConverter creates new BusDataConverter.
+// location is used to parse local timestamps, America/New_York is used if nil.
+// routeIDs maps route_short_name to GTFS route_id (optional): legacy vehicle data
+// only has public route name, without the map it is used as route_id as is.
+func NewBusDataConverter(location *time.Location, routeIDs map[string]string) *BusDataConverter {
+	if location == nil {
+		location = njt.TimeZone()
+	}
+	return &BusDataConverter{
+		location: location,
+		routeIDs: routeIDs,
+	}
+}
+
+// VehiclePositions converts BusDataClient.GetBusVehicleData response into
+// VehiclePositions feed, rows without vehicle ID or valid coordinates are skipped
+func (c *BusDataConverter) VehiclePositions(resp *njtv1.GetBusVehicleDataResponse, now time.Time) *gtfs.FeedMessage {
+	var entities []*gtfs.FeedEntity
+	if resp != nil {
+		for _, row := range resp.Rows {
+			vehicle := c.VehiclePosition(row)
+			if vehicle == nil {
+				continue
+			}
+			entities = append(entities, &gtfs.FeedEntity{
+				Id:      proto.String(vehicle.GetVehicle().GetId()),
+				Vehicle: vehicle,
+			})
+		}
+	}
+
+	return NewFeedMessage(entities, now)
+}
+
+// VehiclePosition converts a single BusVehicleDataRow, returns nil if vehicle ID is empty
+// or coordinates are invalid, including 0,0 reported by vehicles without GPS fix
+func (c *BusDataConverter) VehiclePosition(row njtv1.BusVehicleDataRow) *gtfs.VehiclePosition {
+	vehicleID := strings.TrimSpace(row.VehicleID)
+	if vehicleID == "" {
+		return nil
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(row.Latitude), 32)
+	if err != nil {
+		return nil
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(row.Longitude), 32)
+	if err != nil || !validLatLon(lat, lon) {
+		return nil
+	}
+
+	vehicle := &gtfs.VehiclePosition{
+		Vehicle: &gtfs.VehicleDescriptor{
+			Id:    proto.String(vehicleID),
+			Label: proto.String(vehicleID),
+		},
+		Position: &gtfs.Position{
+			Latitude:  proto.Float32(float32(lat)),
+			Longitude: proto.Float32(float32(lon)),
+		},
+	}
+
+	if route := strings.TrimSpace(row.Route); route != "" {
+		vehicle.Trip = &gtfs.TripDescriptor{
+			RouteId: proto.String(c.routeID(route)),
+		}
+	}
+
+	timestamp := row.GPSTimestmp
+	if timestamp == "" {
+		timestamp = row.LastModified
+	}
+	if t, err := time.ParseInLocation(busDataTimestampLayout, strings.TrimSpace(timestamp), c.location); err == nil {
+		vehicle.Timestamp = proto.Uint64(uint64(t.Unix()))
+	}
+
+	return vehicle
+}
+
+// TripUpdates converts BusDataClient.GetScheduleXGTFS responses (one per site)
+// into TripUpdates feed. Rows of the same gtfs_trip_id are merged into one TripUpdate,
+// sec_late is used as departure delay, rows with empty sec_late are marked NO_DATA.
+func (c *BusDataConverter) TripUpdates(now time.Time, responses ...*njtv1.GetScheduleXGTFSResponse) *gtfs.FeedMessage {
+	type stopTime struct {
+		update    *gtfs.TripUpdate_StopTimeUpdate
+		scheduled time.Time
+	}
+
+	updates := map[int]*gtfs.TripUpdate{}
+	stopTimes := map[int][]stopTime{}
+	seen := map[string]bool{}
+	var tripIDs []int
+
+	for _, resp := range responses {
+		if resp == nil {
+			continue
+		}
+		for _, trip := range resp.Trips {
+			key := strconv.Itoa(trip.GTFSTripID) + "|" + strconv.Itoa(trip.GTFSStopID)
+			if trip.GTFSTripID == 0 || seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if _, ok := updates[trip.GTFSTripID]; !ok {
+				updates[trip.GTFSTripID] = &gtfs.TripUpdate{
+					Trip: &gtfs.TripDescriptor{
+						TripId:               proto.String(strconv.Itoa(trip.GTFSTripID)),
+						RouteId:              proto.String(strconv.Itoa(trip.GTFSRouteID)),
+						ScheduleRelationship: gtfs.TripDescriptor_SCHEDULED.Enum(),
+					},
+					Timestamp: proto.Uint64(uint64(now.Unix())),
+				}
+				tripIDs = append(tripIDs, trip.GTFSTripID)
+			}
+
+			update, scheduled := c.stopTimeUpdate(trip)
+			stopTimes[trip.GTFSTripID] = append(stopTimes[trip.GTFSTripID], stopTime{update: update, scheduled: scheduled})
+		}
+	}
+
+	sort.Ints(tripIDs)
+	entities := make([]*gtfs.FeedEntity, 0, len(tripIDs))
+	for _, tripID := range tripIDs {
+		st := stopTimes[tripID]
+		sort.SliceStable(st, func(i, j int) bool {
+			return st[i].scheduled.Before(st[j].scheduled)
+		})
+
+		update := updates[tripID]
+		for _, s := range st {
+			update.StopTimeUpdate = append(update.StopTimeUpdate, s.update)
+		}
+		if last := update.StopTimeUpdate[len(update.StopTimeUpdate)-1]; last.GetDeparture() != nil {
+			update.Delay = proto.Int32(last.GetDeparture().GetDelay())
+		}
+
+		entities = append(entities, &gtfs.FeedEntity{
+			Id:         proto.String(strconv.Itoa(tripID)),
+			TripUpdate: update,
+		})
+	}
+
+	return NewFeedMessage(entities, now)
+}
+
+func (c *BusDataConverter) stopTimeUpdate(trip njtv1.GetScheduleXGTFSTrip) (*gtfs.TripUpdate_StopTimeUpdate, time.Time) {
+	update := &gtfs.TripUpdate_StopTimeUpdate{
+		StopId: proto.String(strconv.Itoa(trip.GTFSStopID)),
+	}
+
+	scheduled, err := time.ParseInLocation(busDataSchedDepTimeLayout, strings.TrimSpace(trip.SchedDepTime), c.location)
+	secLate, secLateErr := strconv.Atoi(strings.TrimSpace(trip.SecLate))
+	if err != nil || secLateErr != nil {
+		update.ScheduleRelationship = gtfs.TripUpdate_StopTimeUpdate_NO_DATA.Enum()
+		return update, scheduled
+	}
+
+	update.ScheduleRelationship = gtfs.TripUpdate_StopTimeUpdate_SCHEDULED.Enum()
+	update.Departure = &gtfs.TripUpdate_StopTimeEvent{
+		Delay: proto.Int32(int32(secLate)),
+		Time:  proto.Int64(scheduled.Add(time.Duration(secLate) * time.Second).Unix()),
+	}
+	return update, scheduled
+}
+
+func (c *BusDataConverter) routeID(route string) string {
+	if id, ok := c.routeIDs[route]; ok {
+		return id
+	}
+	return route
+}
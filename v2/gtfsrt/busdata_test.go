@@ -0,0 +1,84 @@
+package gtfsrt
+
+import (
+	"testing"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBusDataConverterVehiclePositions(t *testing.T) {
+	c := NewBusDataConverter(time.UTC, map[string]string{"1": "10"})
+	now := time.Date(2019, 4, 25, 0, 17, 0, 0, time.UTC)
+
+	feed := c.VehiclePositions(&njtv1.GetBusVehicleDataResponse{
+		Rows: []njtv1.BusVehicleDataRow{
+			{
+				VehicleID:   "5987",
+				Route:       "1",
+				Longitude:   "-74.24513778686523",
+				Latitude:    "40.73779029846192",
+				GPSTimestmp: "25-Apr-2019 12:15:12 AM",
+			},
+			{VehicleID: "5988", Longitude: "", Latitude: ""},
+			{VehicleID: "5989", Longitude: "0", Latitude: "0"},
+			{VehicleID: "5990", Longitude: "-74.24", Latitude: "140.73"},
+			{VehicleID: "5991", Longitude: "-274.24", Latitude: "40.73"},
+			{VehicleID: " ", Route: "1", Longitude: "-74.24", Latitude: "40.73"},
+		},
+	}, now)
+
+	assert.Equal(t, "2.0", feed.GetHeader().GetGtfsRealtimeVersion())
+	assert.Equal(t, gtfs.FeedHeader_FULL_DATASET, feed.GetHeader().GetIncrementality())
+	assert.Equal(t, uint64(now.Unix()), feed.GetHeader().GetTimestamp())
+	if assert.Len(t, feed.GetEntity(), 1) {
+		vehicle := feed.GetEntity()[0].GetVehicle()
+		assert.Equal(t, "5987", vehicle.GetVehicle().GetId())
+		assert.Equal(t, "10", vehicle.GetTrip().GetRouteId())
+		assert.InDelta(t, 40.73779, vehicle.GetPosition().GetLatitude(), 1e-5)
+		assert.Equal(t, uint64(time.Date(2019, 4, 25, 0, 15, 12, 0, time.UTC).Unix()), vehicle.GetTimestamp())
+	}
+
+	_, err := proto.Marshal(feed)
+	assert.NoError(t, err)
+}
+
+func TestBusDataConverterTripUpdates(t *testing.T) {
+	c := NewBusDataConverter(time.UTC, nil)
+	now := time.Date(2019, 1, 3, 1, 30, 0, 0, time.UTC)
+
+	feed := c.TripUpdates(
+		now,
+		&njtv1.GetScheduleXGTFSResponse{
+			Trips: []njtv1.GetScheduleXGTFSTrip{
+				{GTFSTripID: 100, GTFSStopID: 2, GTFSRouteID: 7, SchedDepTime: "03-JAN-19 01.51.00.000000 AM", SecLate: "120"},
+				{GTFSTripID: 100, GTFSStopID: 1, GTFSRouteID: 7, SchedDepTime: "03-JAN-19 01.41.00.000000 AM", SecLate: "60"},
+			},
+		},
+		&njtv1.GetScheduleXGTFSResponse{
+			Trips: []njtv1.GetScheduleXGTFSTrip{
+				{GTFSTripID: 100, GTFSStopID: 1, GTFSRouteID: 7, SchedDepTime: "03-JAN-19 01.41.00.000000 AM", SecLate: "60"},
+				{GTFSTripID: 200, GTFSStopID: 1, GTFSRouteID: 8, SchedDepTime: "03-JAN-19 02.00.00.000000 AM", SecLate: ""},
+			},
+		},
+	)
+
+	if assert.Len(t, feed.GetEntity(), 2) {
+		update := feed.GetEntity()[0].GetTripUpdate()
+		assert.Equal(t, "100", update.GetTrip().GetTripId())
+		assert.Equal(t, "7", update.GetTrip().GetRouteId())
+		if assert.Len(t, update.GetStopTimeUpdate(), 2) {
+			first := update.GetStopTimeUpdate()[0]
+			assert.Equal(t, "1", first.GetStopId())
+			assert.Equal(t, int32(60), first.GetDeparture().GetDelay())
+			assert.Equal(t, time.Date(2019, 1, 3, 1, 42, 0, 0, time.UTC).Unix(), first.GetDeparture().GetTime())
+		}
+		assert.Equal(t, int32(120), update.GetDelay())
+
+		update = feed.GetEntity()[1].GetTripUpdate()
+		assert.Equal(t, gtfs.TripUpdate_StopTimeUpdate_NO_DATA, update.GetStopTimeUpdate()[0].GetScheduleRelationship())
+	}
+}
@@ -0,0 +1,110 @@
+package gtfsrt
+
+import (
+	"fmt"
+
+	gtfs "github.com/errornil/transit_realtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// Diff computes DIFFERENTIAL feed that turns oldFeed into newFeed:
+// new and changed entities are included as a whole, removed entities
+// are included with is_deleted set. Header timestamp is taken from newFeed.
+func Diff(oldFeed, newFeed *gtfs.FeedMessage) *gtfs.FeedMessage {
+	oldEntities := make(map[string]*gtfs.FeedEntity, len(oldFeed.GetEntity()))
+	for _, entity := range oldFeed.GetEntity() {
+		oldEntities[entity.GetId()] = entity
+	}
+
+	var entities []*gtfs.FeedEntity
+	newIDs := make(map[string]bool, len(newFeed.GetEntity()))
+	for _, entity := range newFeed.GetEntity() {
+		newIDs[entity.GetId()] = true
+		if old, ok := oldEntities[entity.GetId()]; ok && proto.Equal(old, entity) {
+			continue
+		}
+		entities = append(entities, proto.Clone(entity).(*gtfs.FeedEntity))
+	}
+
+	for _, entity := range oldFeed.GetEntity() {
+		if newIDs[entity.GetId()] {
+			continue
+		}
+		entities = append(entities, &gtfs.FeedEntity{
+			Id:        proto.String(entity.GetId()),
+			IsDeleted: proto.Bool(true),
+		})
+	}
+
+	return &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{
+			GtfsRealtimeVersion: proto.String(headerVersion(newFeed)),
+			Incrementality:      gtfs.FeedHeader_DIFFERENTIAL.Enum(),
+			Timestamp:           proto.Uint64(newFeed.GetHeader().GetTimestamp()),
+		},
+		Entity: entities,
+	}
+}
+
+// Apply applies DIFFERENTIAL feed on top of FULL_DATASET feed and returns
+// new FULL_DATASET feed, full is not modified. Entities keep their order,
+// added entities are appended in the order of diff.
+func Apply(full, diff *gtfs.FeedMessage) (*gtfs.FeedMessage, error) {
+	if full.GetHeader().GetIncrementality() != gtfs.FeedHeader_FULL_DATASET {
+		return nil, fmt.Errorf("full feed incrementality is %s", full.GetHeader().GetIncrementality())
+	}
+	if diff.GetHeader().GetIncrementality() != gtfs.FeedHeader_DIFFERENTIAL {
+		return nil, fmt.Errorf("diff feed incrementality is %s", diff.GetHeader().GetIncrementality())
+	}
+
+	changes := make(map[string]*gtfs.FeedEntity, len(diff.GetEntity()))
+	for _, entity := range diff.GetEntity() {
+		if entity.GetId() == "" {
+			return nil, fmt.Errorf("diff entity has no id")
+		}
+		changes[entity.GetId()] = entity
+	}
+
+	var entities []*gtfs.FeedEntity
+	applied := map[string]bool{}
+	for _, entity := range full.GetEntity() {
+		change, ok := changes[entity.GetId()]
+		if !ok {
+			entities = append(entities, proto.Clone(entity).(*gtfs.FeedEntity))
+			continue
+		}
+		applied[entity.GetId()] = true
+		if !change.GetIsDeleted() {
+			entities = append(entities, proto.Clone(change).(*gtfs.FeedEntity))
+		}
+	}
+
+	for _, entity := range diff.GetEntity() {
+		if applied[entity.GetId()] || entity.GetIsDeleted() {
+			continue
+		}
+		applied[entity.GetId()] = true
+		entities = append(entities, proto.Clone(entity).(*gtfs.FeedEntity))
+	}
+
+	timestamp := diff.GetHeader().GetTimestamp()
+	if timestamp == 0 {
+		timestamp = full.GetHeader().GetTimestamp()
+	}
+
+	return &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{
+			GtfsRealtimeVersion: proto.String(headerVersion(full)),
+			Incrementality:      gtfs.FeedHeader_FULL_DATASET.Enum(),
+			Timestamp:           proto.Uint64(timestamp),
+		},
+		Entity: entities,
+	}, nil
+}
+
+func headerVersion(feed *gtfs.FeedMessage) string {
+	if v := feed.GetHeader().GetGtfsRealtimeVersion(); v != "" {
+		return v
+	}
+	return Version
+}
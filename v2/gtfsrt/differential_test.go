@@ -0,0 +1,63 @@
+package gtfsrt
+
+import (
+	"testing"
+	"time"
+
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func vehicleEntity(id string, lat float32) *gtfs.FeedEntity {
+	return &gtfs.FeedEntity{
+		Id: proto.String(id),
+		Vehicle: &gtfs.VehiclePosition{
+			Vehicle:  &gtfs.VehicleDescriptor{Id: proto.String(id)},
+			Position: &gtfs.Position{Latitude: proto.Float32(lat), Longitude: proto.Float32(-74.1)},
+		},
+	}
+}
+
+func TestDiffApplyRoundTrip(t *testing.T) {
+	oldFeed := NewFeedMessage([]*gtfs.FeedEntity{
+		vehicleEntity("1", 40.70),
+		vehicleEntity("2", 40.71),
+		vehicleEntity("3", 40.72),
+	}, time.Unix(1727700000, 0))
+	newFeed := NewFeedMessage([]*gtfs.FeedEntity{
+		vehicleEntity("1", 40.70),
+		vehicleEntity("2", 40.75),
+		vehicleEntity("4", 40.73),
+	}, time.Unix(1727700030, 0))
+
+	diff := Diff(oldFeed, newFeed)
+	assert.Equal(t, gtfs.FeedHeader_DIFFERENTIAL, diff.GetHeader().GetIncrementality())
+	assert.Equal(t, uint64(1727700030), diff.GetHeader().GetTimestamp())
+	if assert.Len(t, diff.GetEntity(), 3) {
+		assert.Equal(t, "2", diff.GetEntity()[0].GetId())
+		assert.Equal(t, "4", diff.GetEntity()[1].GetId())
+		assert.Equal(t, "3", diff.GetEntity()[2].GetId())
+		assert.True(t, diff.GetEntity()[2].GetIsDeleted())
+	}
+
+	// differential feed survives serialization
+	b, err := proto.Marshal(diff)
+	assert.NoError(t, err)
+	decoded := &gtfs.FeedMessage{}
+	assert.NoError(t, proto.Unmarshal(b, decoded))
+
+	applied, err := Apply(oldFeed, decoded)
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(newFeed, applied), "expected %v, got %v", newFeed, applied)
+
+	// oldFeed is not modified
+	assert.Len(t, oldFeed.GetEntity(), 3)
+
+	assert.Empty(t, Diff(newFeed, newFeed).GetEntity())
+
+	_, err = Apply(diff, diff)
+	assert.Error(t, err)
+	_, err = Apply(oldFeed, newFeed)
+	assert.Error(t, err)
+}
@@ -0,0 +1,23 @@
+package gtfsrt
+
+import (
+	"time"
+
+	gtfs "github.com/errornil/transit_realtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// Version is GTFS-Realtime version of produced feeds
+const Version = "2.0"
+
+// NewFeedMessage creates FULL_DATASET FeedMessage with given entities
+func NewFeedMessage(entities []*gtfs.FeedEntity, timestamp time.Time) *gtfs.FeedMessage {
+	return &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{
+			GtfsRealtimeVersion: proto.String(Version),
+			Incrementality:      gtfs.FeedHeader_FULL_DATASET.Enum(),
+			Timestamp:           proto.Uint64(uint64(timestamp.Unix())),
+		},
+		Entity: entities,
+	}
+}
@@ -0,0 +1,252 @@
+package gtfsrt
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+	gtfs "github.com/errornil/transit_realtime"
+)
+
+// Health is an overall feed freshness status
+type Health string
+
+// Health statuses
+const (
+	HealthOK        Health = "ok"
+	HealthDegraded  Health = "degraded"   // feed is fresh, but most of its entities are stale
+	HealthStale     Health = "stale"      // feed is older than MaxFeedAge or stopped changing
+	HealthClockSkew Health = "clock_skew" // feed timestamp is in the future
+)
+
+// Thresholds configure freshness checks
+type Thresholds struct {
+	MaxFeedAge    time.Duration // feed older than this is stale
+	MaxEntityAge  time.Duration // vehicle or trip update older than this is stale
+	MaxFutureSkew time.Duration // timestamps further in the future are clock skew
+}
+
+// DefaultThresholds are suitable for feeds polled every 30 seconds
+var DefaultThresholds = Thresholds{
+	MaxFeedAge:    2 * time.Minute,
+	MaxEntityAge:  5 * time.Minute,
+	MaxFutureSkew: time.Minute,
+}
+
+// Freshness is a freshness report of a single fetch
+type Freshness struct {
+	Health         Health
+	FeedTime       time.Time // header timestamp, or the newest entity timestamp for legacy feeds
+	FeedAge        time.Duration
+	Entities       []EntityFreshness
+	StaleEntities  int
+	FutureEntities int
+}
+
+// EntityFreshness is age of a single vehicle or trip update
+type EntityFreshness struct {
+	EntityID string
+	Time     time.Time
+	Age      time.Duration // negative if timestamp is in the future
+	Stale    bool
+	Future   bool
+}
+
+// CheckFeed reports freshness of GTFS-RT feed fetched at now,
+// entities without timestamps get the header timestamp
+func CheckFeed(feed *gtfs.FeedMessage, now time.Time, thresholds Thresholds) Freshness {
+	var feedTime time.Time
+	if ts := feed.GetHeader().GetTimestamp(); ts != 0 {
+		feedTime = time.Unix(int64(ts), 0)
+	}
+
+	f := Freshness{FeedTime: feedTime}
+	for _, entity := range feed.GetEntity() {
+		ts := entity.GetVehicle().GetTimestamp()
+		if entity.GetVehicle() == nil {
+			ts = entity.GetTripUpdate().GetTimestamp()
+		}
+		if entity.GetVehicle() == nil && entity.GetTripUpdate() == nil {
+			continue
+		}
+
+		t := feedTime
+		if ts != 0 {
+			t = time.Unix(int64(ts), 0)
+		}
+		f.add(entity.GetId(), t, now, thresholds)
+	}
+
+	f.finish(now, thresholds)
+	return f
+}
+
+// CheckBusVehicleData reports freshness of BusDataClient.GetBusVehicleData response
+// fetched at now, using GPS_TIMESTMP of each row (LAST_MODIFIED if empty).
+// Legacy feed has no header timestamp, the newest LAST_MODIFIED is used as feed time.
+// location is used to parse local timestamps, America/New_York is used if nil.
+func CheckBusVehicleData(resp *njtv1.GetBusVehicleDataResponse, now time.Time, location *time.Location, thresholds Thresholds) Freshness {
+	if location == nil {
+		location = njt.TimeZone()
+	}
+
+	f := Freshness{}
+	if resp == nil {
+		f.finish(now, thresholds)
+		return f
+	}
+
+	for _, row := range resp.Rows {
+		modified, err := time.ParseInLocation(busDataTimestampLayout, strings.TrimSpace(row.LastModified), location)
+		if err == nil && modified.After(f.FeedTime) {
+			f.FeedTime = modified
+		}
+
+		t, err := time.ParseInLocation(busDataTimestampLayout, strings.TrimSpace(row.GPSTimestmp), location)
+		if err != nil {
+			t = modified
+		}
+		f.add(row.VehicleID, t, now, thresholds)
+	}
+
+	f.finish(now, thresholds)
+	return f
+}
+
+// FilterStaleVehicles returns a copy of the feed without stale vehicle positions
+// and vehicle positions with timestamps in the future; other entities are kept
+func FilterStaleVehicles(feed *gtfs.FeedMessage, now time.Time, thresholds Thresholds) *gtfs.FeedMessage {
+	freshness := CheckFeed(feed, now, thresholds)
+	drop := map[string]bool{}
+	for _, e := range freshness.Entities {
+		drop[e.EntityID] = e.Stale || e.Future
+	}
+
+	filtered := &gtfs.FeedMessage{Header: feed.GetHeader()}
+	for _, entity := range feed.GetEntity() {
+		if entity.GetVehicle() != nil && drop[entity.GetId()] {
+			continue
+		}
+		filtered.Entity = append(filtered.Entity, entity)
+	}
+	return filtered
+}
+
+// FilterStaleBusVehicleData returns BusDataClient.GetBusVehicleData response
+// without stale rows and rows with timestamps in the future, nil response is returned as empty
+func FilterStaleBusVehicleData(resp *njtv1.GetBusVehicleDataResponse, now time.Time, location *time.Location, thresholds Thresholds) *njtv1.GetBusVehicleDataResponse {
+	if resp == nil {
+		return &njtv1.GetBusVehicleDataResponse{}
+	}
+
+	freshness := CheckBusVehicleData(resp, now, location, thresholds)
+
+	filtered := &njtv1.GetBusVehicleDataResponse{}
+	for i, row := range resp.Rows {
+		if e := freshness.Entities[i]; e.Stale || e.Future {
+			continue
+		}
+		filtered.Rows = append(filtered.Rows, row)
+	}
+	return filtered
+}
+
+func (f *Freshness) add(id string, t, now time.Time, thresholds Thresholds) {
+	e := EntityFreshness{EntityID: id, Time: t}
+	if t.IsZero() {
+		// no timestamp at all, can't tell its age
+		e.Stale = true
+	} else {
+		e.Age = now.Sub(t)
+		e.Stale = e.Age > thresholds.MaxEntityAge
+		e.Future = -e.Age > thresholds.MaxFutureSkew
+	}
+
+	if e.Stale {
+		f.StaleEntities++
+	}
+	if e.Future {
+		f.FutureEntities++
+	}
+	f.Entities = append(f.Entities, e)
+}
+
+func (f *Freshness) finish(now time.Time, thresholds Thresholds) {
+	switch {
+	case f.FeedTime.IsZero():
+		f.Health = HealthStale
+	case f.FeedTime.Sub(now) > thresholds.MaxFutureSkew:
+		f.Health = HealthClockSkew
+	case now.Sub(f.FeedTime) > thresholds.MaxFeedAge:
+		f.Health = HealthStale
+	case len(f.Entities) > 0 && (f.StaleEntities+f.FutureEntities)*2 > len(f.Entities):
+		f.Health = HealthDegraded
+	default:
+		f.Health = HealthOK
+	}
+	if !f.FeedTime.IsZero() {
+		f.FeedAge = now.Sub(f.FeedTime)
+	}
+}
+
+// Monitor keeps the latest Freshness of named feeds and detects feeds
+// that keep serving the same timestamp: if feed time did not change for
+// longer than MaxFeedAge, feed is reported stale even if NJ TRANSIT clock
+// makes it look recent. Monitor is safe for concurrent use.
+type Monitor struct {
+	thresholds Thresholds
+
+	mu     sync.RWMutex
+	feeds  map[string]Freshness
+	change map[string]time.Time // when feed time last changed
+}
+
+// NewMonitor creates new Monitor
+func NewMonitor(thresholds Thresholds) *Monitor {
+	return &Monitor{
+		thresholds: thresholds,
+		feeds:      map[string]Freshness{},
+		change:     map[string]time.Time{},
+	}
+}
+
+// Observe records freshness of a fetch of named feed and returns it
+// with Health adjusted for feeds that stopped changing
+func (m *Monitor) Observe(name string, f Freshness, now time.Time) Freshness {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous, ok := m.feeds[name]
+	if !ok || !previous.FeedTime.Equal(f.FeedTime) {
+		m.change[name] = now
+	}
+	if now.Sub(m.change[name]) > m.thresholds.MaxFeedAge && f.Health == HealthOK {
+		f.Health = HealthStale
+	}
+
+	m.feeds[name] = f
+	return f
+}
+
+// Health returns the latest Health of each observed feed
+func (m *Monitor) Health() map[string]Health {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	health := make(map[string]Health, len(m.feeds))
+	for name, f := range m.feeds {
+		health[name] = f.Health
+	}
+	return health
+}
+
+// Freshness returns the latest Freshness of named feed
+func (m *Monitor) Freshness(name string) (Freshness, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	f, ok := m.feeds[name]
+	return f, ok
+}
@@ -0,0 +1,84 @@
+package gtfsrt
+
+import (
+	"testing"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCheckFeed(t *testing.T) {
+	now := time.Unix(1727700000, 0)
+	vehicle := func(id string, age time.Duration) *gtfs.FeedEntity {
+		return &gtfs.FeedEntity{
+			Id:      proto.String(id),
+			Vehicle: &gtfs.VehiclePosition{Timestamp: proto.Uint64(uint64(now.Add(-age).Unix()))},
+		}
+	}
+	feed := NewFeedMessage([]*gtfs.FeedEntity{
+		vehicle("fresh", 30*time.Second),
+		vehicle("stale", 10*time.Minute),
+		vehicle("future", -5*time.Minute),
+		{Id: proto.String("alert"), Alert: &gtfs.Alert{}},
+	}, now.Add(-time.Minute))
+
+	f := CheckFeed(feed, now, DefaultThresholds)
+	assert.Equal(t, HealthDegraded, f.Health)
+	assert.Equal(t, time.Minute, f.FeedAge)
+	assert.Len(t, f.Entities, 3)
+	assert.Equal(t, 1, f.StaleEntities)
+	assert.Equal(t, 1, f.FutureEntities)
+
+	filtered := FilterStaleVehicles(feed, now, DefaultThresholds)
+	if assert.Len(t, filtered.Entity, 2) {
+		assert.Equal(t, "fresh", filtered.Entity[0].GetId())
+		assert.Equal(t, "alert", filtered.Entity[1].GetId())
+	}
+
+	assert.Equal(t, HealthStale, CheckFeed(feed, now.Add(time.Hour), DefaultThresholds).Health)
+	assert.Equal(t, HealthClockSkew, CheckFeed(feed, now.Add(-time.Hour), DefaultThresholds).Health)
+}
+
+func TestCheckBusVehicleData(t *testing.T) {
+	location := njt.TimeZone()
+	now := time.Date(2019, 4, 25, 0, 16, 0, 0, location)
+	resp := &njtv1.GetBusVehicleDataResponse{Rows: []njtv1.BusVehicleDataRow{
+		{VehicleID: "5987", GPSTimestmp: "25-Apr-2019 12:15:12 AM", LastModified: "25-Apr-2019 12:15:30 AM"},
+		{VehicleID: "6001", GPSTimestmp: "25-Apr-2019 12:01:00 AM", LastModified: "25-Apr-2019 12:01:10 AM"},
+	}}
+
+	f := CheckBusVehicleData(resp, now, nil, DefaultThresholds)
+	assert.Equal(t, HealthOK, f.Health)
+	assert.Equal(t, 30*time.Second, f.FeedAge)
+	assert.Equal(t, 1, f.StaleEntities)
+
+	filtered := FilterStaleBusVehicleData(resp, now, nil, DefaultThresholds)
+	if assert.Len(t, filtered.Rows, 1) {
+		assert.Equal(t, "5987", filtered.Rows[0].VehicleID)
+	}
+
+	assert.Equal(t, HealthStale, CheckBusVehicleData(nil, now, nil, DefaultThresholds).Health)
+	assert.Equal(t, &njtv1.GetBusVehicleDataResponse{}, FilterStaleBusVehicleData(nil, now, nil, DefaultThresholds))
+}
+
+func TestMonitor(t *testing.T) {
+	m := NewMonitor(DefaultThresholds)
+	start := time.Unix(1727700000, 0)
+	feed := NewFeedMessage(nil, start)
+
+	// NJ TRANSIT clock runs late, header looks fresh but never changes
+	for i := 0; i <= 4; i++ {
+		now := start.Add(time.Duration(i) * time.Minute)
+		m.Observe("bus", CheckFeed(feed, now.Add(-time.Duration(i)*time.Minute), DefaultThresholds), now)
+	}
+	assert.Equal(t, map[string]Health{"bus": HealthStale}, m.Health())
+
+	m.Observe("bus", CheckFeed(NewFeedMessage(nil, start.Add(5*time.Minute)), start.Add(5*time.Minute), DefaultThresholds), start.Add(5*time.Minute))
+	f, ok := m.Freshness("bus")
+	assert.True(t, ok)
+	assert.Equal(t, HealthOK, f.Health)
+}
@@ -0,0 +1,114 @@
+package gtfsrt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	gtfs "github.com/errornil/transit_realtime"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// FeedHandler serves the latest FeedMessage over HTTP as protobuf,
+// or as JSON for debugging with ?format=json.
+// Encoded feed is cached between updates and ETag/If-None-Match is supported,
+// so many consumers can poll it cheaply.
+type FeedHandler struct {
+	maxAge time.Duration
+
+	mu       sync.RWMutex
+	body     []byte
+	jsonBody []byte
+	etag     string
+	modified time.Time
+}
+
+// NewFeedHandler creates new FeedHandler,
+// maxAge is sent in Cache-Control header, usually equal to refresh interval
+func NewFeedHandler(maxAge time.Duration) *FeedHandler {
+	return &FeedHandler{maxAge: maxAge}
+}
+
+// Update replaces served feed
+func (h *FeedHandler) Update(feed *gtfs.FeedMessage) error {
+	body, err := proto.Marshal(feed)
+	if err != nil {
+		return fmt.Errorf("marshal feed: %v", err)
+	}
+
+	jsonBody, err := protojson.MarshalOptions{Indent: "  "}.Marshal(feed)
+	if err != nil {
+		return fmt.Errorf("marshal feed to JSON: %v", err)
+	}
+
+	sum := sha256.Sum256(body)
+	modified := time.Now()
+	if ts := feed.GetHeader().GetTimestamp(); ts != 0 {
+		modified = time.Unix(int64(ts), 0)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.body = body
+	h.jsonBody = jsonBody
+	h.etag = hex.EncodeToString(sum[:16])
+	h.modified = modified
+	return nil
+}
+
+// ServeHTTP implements http.Handler
+func (h *FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.RLock()
+	body, etag, modified := h.body, h.etag, h.modified
+	contentType := "application/x-protobuf"
+	if r.URL.Query().Get("format") == "json" {
+		body = h.jsonBody
+		etag += "-json"
+		contentType = "application/json"
+	}
+	h.mu.RUnlock()
+
+	if body == nil {
+		http.Error(w, "feed is not available yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	etag = `"` + etag + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.maxAge.Seconds())))
+
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(body)
+	}
+}
+
+func matchETag(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == etag || v == "*" {
+			return true
+		}
+	}
+	return false
+}
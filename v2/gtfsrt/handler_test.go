@@ -0,0 +1,50 @@
+package gtfsrt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFeedHandler(t *testing.T) {
+	h := NewFeedHandler(30 * time.Second)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/vehiclepositions.pb", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	feed := NewFeedMessage([]*gtfs.FeedEntity{
+		{Id: proto.String("5987"), Vehicle: &gtfs.VehiclePosition{}},
+	}, time.Unix(1727700000, 0))
+	assert.NoError(t, h.Update(feed))
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/vehiclepositions.pb", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-protobuf", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=30", rec.Header().Get("Cache-Control"))
+
+	decoded := &gtfs.FeedMessage{}
+	assert.NoError(t, proto.Unmarshal(rec.Body.Bytes(), decoded))
+	assert.Equal(t, "5987", decoded.GetEntity()[0].GetId())
+
+	etag := rec.Header().Get("ETag")
+	req := httptest.NewRequest(http.MethodGet, "/vehiclepositions.pb", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.Bytes())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/vehiclepositions.pb?format=json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+	assert.Contains(t, rec.Body.String(), `"5987"`)
+}
@@ -0,0 +1,113 @@
+package gtfsrt
+
+import (
+	"fmt"
+	"strings"
+
+	gtfs "github.com/errornil/transit_realtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// Source is a feed to merge, entity IDs are prefixed with "Namespace:"
+// to avoid collisions between feeds; empty Namespace keeps IDs as is.
+// Vehicle positions are deduped only between sources with the same non-empty
+// DedupeGroup, e.g. "bus" for BusClient feed and BusDataConverter output,
+// so a train and a bus sharing a vehicle ID are both kept.
+type Source struct {
+	Namespace   string
+	DedupeGroup string
+	Feed        *gtfs.FeedMessage
+}
+
+type vehicleKey struct {
+	group string
+	id    string
+}
+
+// Merge combines several FULL_DATASET feeds (bus, rail, light rail) into one.
+// Header timestamp of the result is the oldest of the sources' timestamps,
+// so the merged feed never looks fresher than any of its parts.
+// Vehicle positions with the same vehicle ID reported by sources of the same
+// DedupeGroup (e.g. GTFS-RT feed and BusDataConverter output of legacy
+// GetBusVehicleData rows) are kept once: the most recent one, or the one from
+// the earlier source if timestamps are equal. Only the vehicle position of the
+// other entity is dropped, its trip update or alert is kept.
+// Deduplication runs before entity ID collision check, so sources of the same group
+// may use the same IDs, e.g. vehicle IDs as BusDataConverter does.
+func Merge(sources ...Source) (*gtfs.FeedMessage, error) {
+	var (
+		timestamp uint64
+		entities  []*gtfs.FeedEntity
+		ids       = map[string]int{}     // index in entities
+		vehicles  = map[vehicleKey]int{} // index in entities
+	)
+
+	for _, source := range sources {
+		if source.Feed == nil {
+			continue
+		}
+		header := source.Feed.GetHeader()
+		if header.GetIncrementality() != gtfs.FeedHeader_FULL_DATASET {
+			return nil, fmt.Errorf("%s: only FULL_DATASET feeds can be merged", source.Namespace)
+		}
+		if ts := header.GetTimestamp(); ts != 0 && (timestamp == 0 || ts < timestamp) {
+			timestamp = ts
+		}
+
+		for _, entity := range source.Feed.GetEntity() {
+			entity = proto.Clone(entity).(*gtfs.FeedEntity)
+			if source.Namespace != "" {
+				entity.Id = proto.String(source.Namespace + ":" + entity.GetId())
+			}
+
+			key := vehicleKey{group: source.DedupeGroup, id: strings.TrimSpace(entity.GetVehicle().GetVehicle().GetId())}
+			if key.group != "" && key.id != "" {
+				if i, ok := vehicles[key]; ok {
+					if entity.GetVehicle().GetTimestamp() > entities[i].GetVehicle().GetTimestamp() {
+						entities[i].Vehicle = nil
+						if emptyEntity(entities[i]) {
+							delete(ids, entities[i].GetId())
+							entities[i] = nil
+						}
+					} else {
+						entity.Vehicle = nil
+					}
+				}
+			}
+			if emptyEntity(entity) {
+				continue
+			}
+
+			if _, ok := ids[entity.GetId()]; ok {
+				return nil, fmt.Errorf("duplicate entity id %q", entity.GetId())
+			}
+			if entity.GetVehicle() != nil && key.group != "" && key.id != "" {
+				vehicles[key] = len(entities)
+			}
+			ids[entity.GetId()] = len(entities)
+			entities = append(entities, entity)
+		}
+	}
+
+	// remove entities left empty by deduplication
+	merged := make([]*gtfs.FeedEntity, 0, len(entities))
+	for _, entity := range entities {
+		if entity != nil {
+			merged = append(merged, entity)
+		}
+	}
+
+	return &gtfs.FeedMessage{
+		Header: &gtfs.FeedHeader{
+			GtfsRealtimeVersion: proto.String(Version),
+			Incrementality:      gtfs.FeedHeader_FULL_DATASET.Enum(),
+			Timestamp:           proto.Uint64(timestamp),
+		},
+		Entity: merged,
+	}, nil
+}
+
+// emptyEntity reports whether entity has nothing left after its vehicle position was deduplicated
+func emptyEntity(entity *gtfs.FeedEntity) bool {
+	return entity.GetTripUpdate() == nil && entity.GetVehicle() == nil && entity.GetAlert() == nil
+}
@@ -0,0 +1,112 @@
+package gtfsrt
+
+import (
+	"testing"
+	"time"
+
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func timedVehicleEntity(id, vehicleID string, timestamp uint64) *gtfs.FeedEntity {
+	return &gtfs.FeedEntity{
+		Id: proto.String(id),
+		Vehicle: &gtfs.VehiclePosition{
+			Vehicle:   &gtfs.VehicleDescriptor{Id: proto.String(vehicleID)},
+			Timestamp: proto.Uint64(timestamp),
+		},
+	}
+}
+
+func entityIDs(feed *gtfs.FeedMessage) []string {
+	var ids []string
+	for _, entity := range feed.GetEntity() {
+		ids = append(ids, entity.GetId())
+	}
+	return ids
+}
+
+func TestMerge(t *testing.T) {
+	bus := NewFeedMessage([]*gtfs.FeedEntity{
+		timedVehicleEntity("1", "5987", 100),
+		timedVehicleEntity("2", "6001", 100),
+		timedVehicleEntity("3", "7000", 100),
+	}, time.Unix(200, 0))
+	legacy := NewFeedMessage([]*gtfs.FeedEntity{
+		timedVehicleEntity("1", "5987", 150), // newer wins
+		timedVehicleEntity("2", "6001", 100), // equal timestamp, earlier source wins
+	}, time.Unix(150, 0))
+	rail := NewFeedMessage([]*gtfs.FeedEntity{
+		timedVehicleEntity("1", "5987", 300), // same ID as a bus, different group
+	}, time.Unix(300, 0))
+
+	merged, err := Merge(
+		Source{Namespace: "bus", DedupeGroup: "bus", Feed: bus},
+		Source{Namespace: "busdata", DedupeGroup: "bus", Feed: legacy},
+		Source{Namespace: "rail", Feed: rail},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(150), merged.GetHeader().GetTimestamp())
+	assert.Equal(t, []string{"bus:2", "bus:3", "busdata:1", "rail:1"}, entityIDs(merged))
+	assert.Equal(t, uint64(150), merged.GetEntity()[2].GetVehicle().GetTimestamp())
+
+	// sources are not modified
+	assert.Equal(t, "1", bus.GetEntity()[0].GetId())
+}
+
+func TestMergeDedupeSameIDs(t *testing.T) {
+	// BusDataConverter uses vehicle IDs as entity IDs, same as the bus feed
+	bus := NewFeedMessage([]*gtfs.FeedEntity{timedVehicleEntity("5987", "5987", 100)}, time.Unix(100, 0))
+	legacy := NewFeedMessage([]*gtfs.FeedEntity{timedVehicleEntity("5987", "5987", 150)}, time.Unix(150, 0))
+
+	merged, err := Merge(
+		Source{DedupeGroup: "bus", Feed: bus},
+		Source{DedupeGroup: "bus", Feed: legacy},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"5987"}, entityIDs(merged))
+	assert.Equal(t, uint64(150), merged.GetEntity()[0].GetVehicle().GetTimestamp())
+}
+
+func TestMergeDedupeKeepsTripUpdate(t *testing.T) {
+	withTrip := timedVehicleEntity("1", "5987", 100)
+	withTrip.TripUpdate = &gtfs.TripUpdate{Trip: &gtfs.TripDescriptor{TripId: proto.String("trip1")}}
+	bus := NewFeedMessage([]*gtfs.FeedEntity{withTrip}, time.Unix(100, 0))
+	legacy := NewFeedMessage([]*gtfs.FeedEntity{timedVehicleEntity("1", "5987", 150)}, time.Unix(150, 0))
+
+	merged, err := Merge(
+		Source{Namespace: "bus", DedupeGroup: "bus", Feed: bus},
+		Source{Namespace: "busdata", DedupeGroup: "bus", Feed: legacy},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bus:1", "busdata:1"}, entityIDs(merged))
+	assert.Nil(t, merged.GetEntity()[0].GetVehicle())
+	assert.Equal(t, "trip1", merged.GetEntity()[0].GetTripUpdate().GetTrip().GetTripId())
+	assert.Equal(t, uint64(150), merged.GetEntity()[1].GetVehicle().GetTimestamp())
+
+	// losing vehicle is dropped, trip update of the same entity is kept
+	merged, err = Merge(
+		Source{Namespace: "busdata", DedupeGroup: "bus", Feed: legacy},
+		Source{Namespace: "bus", DedupeGroup: "bus", Feed: bus},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"busdata:1", "bus:1"}, entityIDs(merged))
+	assert.Nil(t, merged.GetEntity()[1].GetVehicle())
+	assert.NotNil(t, merged.GetEntity()[1].GetTripUpdate())
+}
+
+func TestMergeErrors(t *testing.T) {
+	feed := NewFeedMessage([]*gtfs.FeedEntity{timedVehicleEntity("1", "5987", 100)}, time.Unix(100, 0))
+
+	_, err := Merge(Source{Feed: feed}, Source{Feed: feed})
+	assert.EqualError(t, err, `duplicate entity id "1"`)
+
+	_, err = Merge(Source{Namespace: "a", Feed: feed}, Source{Namespace: "b", Feed: feed})
+	assert.NoError(t, err)
+
+	differential := NewFeedMessage(nil, time.Unix(100, 0))
+	differential.Header.Incrementality = gtfs.FeedHeader_DIFFERENTIAL.Enum()
+	_, err = Merge(Source{Namespace: "rail", Feed: differential})
+	assert.Error(t, err)
+}
@@ -0,0 +1,354 @@
+package gtfsrt
+
+import (
+	"fmt"
+	"hash/crc32"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+	gtfs "github.com/errornil/transit_realtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// trainDataTimeLayout is used by SCHED_DEP_DATE, LAST_MODIFIED and GPSTIME fields,
+// example: 24-May-2019 09:22:30 AM
+const trainDataTimeLayout = "02-Jan-2006 03:04:05 PM"
+
+// RailConverter assembles TrainDataClient responses from multiple stations
+// into GTFS-Realtime feeds: rail has no GTFS-RT feed of its own.
+// Add responses for each polled station, then build feeds. RailConverter is safe for concurrent use.
+type RailConverter struct {
+	location *time.Location
+	tripIDs  map[string]string
+	stopIDs  map[string]string
+
+	mu      sync.Mutex
+	trains  map[string][]railStop // by train ID
+	gps     map[string]railGPS    // by train ID
+	banners map[string][]string   // banner text to station codes
+}
+
+// railStop is a train departure from a station
+type railStop struct {
+	station   string
+	scheduled time.Time
+	secLate   int
+	hasDelay  bool
+	status    string
+}
+
+// railGPS is the latest known train position
+type railGPS struct {
+	lat, lon  float32
+	timestamp time.Time
+}
+
+// NewRailConverter creates new RailConverter.
+// location is used to parse local timestamps, America/New_York is used if nil.
+// tripIDs maps train ID to GTFS trip_id (block_id in NJ TRANSIT rail GTFS),
+// stopIDs maps two-character station code to GTFS stop_id; both are optional,
+// without them train ID and station code are used as is.
+func NewRailConverter(location *time.Location, tripIDs, stopIDs map[string]string) *RailConverter {
+	if location == nil {
+		location = njt.TimeZone()
+	}
+	c := &RailConverter{
+		location: location,
+		tripIDs:  tripIDs,
+		stopIDs:  stopIDs,
+	}
+	c.Reset()
+	return c
+}
+
+// Reset removes all added responses, call it before each polling round
+func (c *RailConverter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.trains = map[string][]railStop{}
+	c.gps = map[string]railGPS{}
+	c.banners = map[string][]string{}
+}
+
+// AddTrainSchedule19Rec adds TrainDataClient.GetTrainSchedule19Rec response for a station,
+// responses without station code are skipped
+func (c *RailConverter) AddTrainSchedule19Rec(resp *njtv1.GetTrainSchedule19RecResponse) {
+	if resp == nil || strings.TrimSpace(resp.TwoChar) == "" {
+		return
+	}
+	station := strings.TrimSpace(resp.TwoChar)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range resp.Items {
+		if item == nil {
+			continue
+		}
+		c.addItem(station, item.TrainID, item.SchedDepDate, item.SecLate, item.Status,
+			item.GPSLatitude, item.GPSLongitude, item.GPSTime)
+	}
+}
+
+// AddStationMessage adds TrainDataClient.GetStationMessage response for a station,
+// its banner message is converted into an Alert; responses without station code are skipped
+func (c *RailConverter) AddStationMessage(resp *njtv1.GetStationMessageResponse) {
+	if resp == nil || strings.TrimSpace(resp.TwoChar) == "" {
+		return
+	}
+	station := strings.TrimSpace(resp.TwoChar)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range resp.Items {
+		if item == nil {
+			continue
+		}
+		c.addItem(station, item.TrainID, item.SchedDepDate, item.SecLate, item.Status,
+			item.GPSLatitude, item.GPSLongitude, item.GPSTime)
+	}
+
+	banner := strings.TrimSpace(html.UnescapeString(resp.BannerMessage))
+	if banner != "" && !contains(c.banners[banner], station) {
+		c.banners[banner] = append(c.banners[banner], station)
+	}
+}
+
+func (c *RailConverter) addItem(station, trainID, schedDepDate, secLate, status, lat, lon, gpsTime string) {
+	trainID = strings.TrimSpace(trainID)
+	if trainID == "" {
+		return
+	}
+
+	scheduled, err := time.ParseInLocation(trainDataTimeLayout, strings.TrimSpace(schedDepDate), c.location)
+	if err != nil {
+		return
+	}
+
+	stop := railStop{
+		station:   station,
+		scheduled: scheduled,
+		status:    strings.TrimSpace(status),
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(secLate)); err == nil {
+		stop.secLate = v
+		stop.hasDelay = true
+	}
+
+	// the same station can be added twice, e.g. from both 19Rec and station message responses
+	stops := c.trains[trainID]
+	replaced := false
+	for i := range stops {
+		if stops[i].station == station {
+			stops[i] = stop
+			replaced = true
+		}
+	}
+	if !replaced {
+		stops = append(stops, stop)
+	}
+	c.trains[trainID] = stops
+
+	latValue, latErr := strconv.ParseFloat(strings.TrimSpace(lat), 32)
+	lonValue, lonErr := strconv.ParseFloat(strings.TrimSpace(lon), 32)
+	if latErr != nil || lonErr != nil || !validLatLon(latValue, lonValue) {
+		return
+	}
+	timestamp, err := time.ParseInLocation(trainDataTimeLayout, strings.TrimSpace(gpsTime), c.location)
+	if err != nil {
+		return
+	}
+	if current, ok := c.gps[trainID]; ok && current.timestamp.After(timestamp) {
+		return
+	}
+	c.gps[trainID] = railGPS{lat: float32(latValue), lon: float32(lonValue), timestamp: timestamp}
+}
+
+// TripUpdates builds TripUpdates feed with a TripUpdate per train,
+// stop time updates are ordered by scheduled departure
+func (c *RailConverter) TripUpdates(now time.Time) *gtfs.FeedMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var entities []*gtfs.FeedEntity
+	for _, trainID := range sortedKeys(c.trains) {
+		stops := append([]railStop(nil), c.trains[trainID]...)
+		sort.Slice(stops, func(i, j int) bool {
+			return stops[i].scheduled.Before(stops[j].scheduled)
+		})
+
+		update := &gtfs.TripUpdate{
+			Trip:      c.tripDescriptor(trainID),
+			Vehicle:   &gtfs.VehicleDescriptor{Id: proto.String(trainID), Label: proto.String(trainID)},
+			Timestamp: proto.Uint64(uint64(now.Unix())),
+		}
+
+		next := false // update.Delay is taken from the next upcoming stop
+		for _, stop := range stops {
+			if isCancelled(stop.status) {
+				update.Trip.ScheduleRelationship = gtfs.TripDescriptor_CANCELED.Enum()
+			}
+
+			stopTimeUpdate := &gtfs.TripUpdate_StopTimeUpdate{
+				StopId:               proto.String(c.stopID(stop.station)),
+				ScheduleRelationship: gtfs.TripUpdate_StopTimeUpdate_SCHEDULED.Enum(),
+			}
+			if stop.hasDelay {
+				departure := stop.scheduled.Add(time.Duration(stop.secLate) * time.Second)
+				stopTimeUpdate.Departure = &gtfs.TripUpdate_StopTimeEvent{
+					Delay: proto.Int32(int32(stop.secLate)),
+					Time:  proto.Int64(departure.Unix()),
+				}
+				// delay of the next upcoming stop, or of the last one if all stops are past
+				if !next {
+					update.Delay = proto.Int32(int32(stop.secLate))
+					next = !departure.Before(now)
+				}
+			} else {
+				stopTimeUpdate.ScheduleRelationship = gtfs.TripUpdate_StopTimeUpdate_NO_DATA.Enum()
+			}
+			update.StopTimeUpdate = append(update.StopTimeUpdate, stopTimeUpdate)
+		}
+
+		if update.Trip.GetScheduleRelationship() == gtfs.TripDescriptor_CANCELED {
+			// spec: stop_time_update should be empty for canceled trips
+			update.StopTimeUpdate = nil
+			update.Delay = nil
+		}
+
+		entities = append(entities, &gtfs.FeedEntity{
+			Id:         proto.String(trainID),
+			TripUpdate: update,
+		})
+	}
+
+	return NewFeedMessage(entities, now)
+}
+
+// VehiclePositions builds VehiclePositions feed for trains that report GPS coordinates
+func (c *RailConverter) VehiclePositions(now time.Time) *gtfs.FeedMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var entities []*gtfs.FeedEntity
+	for _, trainID := range sortedKeys(c.gps) {
+		gps := c.gps[trainID]
+		vehicle := &gtfs.VehiclePosition{
+			Trip:    c.tripDescriptor(trainID),
+			Vehicle: &gtfs.VehicleDescriptor{Id: proto.String(trainID), Label: proto.String(trainID)},
+			Position: &gtfs.Position{
+				Latitude:  proto.Float32(gps.lat),
+				Longitude: proto.Float32(gps.lon),
+			},
+			Timestamp: proto.Uint64(uint64(gps.timestamp.Unix())),
+		}
+
+		// the next station is the earliest departure among polled stations
+		var next *railStop
+		for i, stop := range c.trains[trainID] {
+			if next == nil || stop.scheduled.Before(next.scheduled) {
+				next = &c.trains[trainID][i]
+			}
+		}
+		if next != nil {
+			vehicle.StopId = proto.String(c.stopID(next.station))
+			vehicle.CurrentStatus = gtfs.VehiclePosition_IN_TRANSIT_TO.Enum()
+		}
+
+		entities = append(entities, &gtfs.FeedEntity{
+			Id:      proto.String(trainID),
+			Vehicle: vehicle,
+		})
+	}
+
+	return NewFeedMessage(entities, now)
+}
+
+// Alerts builds Alerts feed from station banner messages,
+// the same message shown at several stations becomes one Alert
+func (c *RailConverter) Alerts(now time.Time) *gtfs.FeedMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var entities []*gtfs.FeedEntity
+	for _, text := range sortedKeys(c.banners) {
+		alert := &gtfs.Alert{
+			HeaderText: translatedString(text),
+			Cause:      gtfs.Alert_UNKNOWN_CAUSE.Enum(),
+			Effect:     gtfs.Alert_UNKNOWN_EFFECT.Enum(),
+		}
+		for _, station := range c.banners[text] {
+			alert.InformedEntity = append(alert.InformedEntity, &gtfs.EntitySelector{
+				StopId: proto.String(c.stopID(station)),
+			})
+		}
+
+		entities = append(entities, &gtfs.FeedEntity{
+			Id:    proto.String(fmt.Sprintf("banner-%08x", crc32.ChecksumIEEE([]byte(text)))),
+			Alert: alert,
+		})
+	}
+
+	return NewFeedMessage(entities, now)
+}
+
+func (c *RailConverter) tripDescriptor(trainID string) *gtfs.TripDescriptor {
+	tripID := trainID
+	if id, ok := c.tripIDs[trainID]; ok {
+		tripID = id
+	}
+	return &gtfs.TripDescriptor{
+		TripId:               proto.String(tripID),
+		ScheduleRelationship: gtfs.TripDescriptor_SCHEDULED.Enum(),
+	}
+}
+
+func (c *RailConverter) stopID(station string) string {
+	if id, ok := c.stopIDs[station]; ok {
+		return id
+	}
+	return station
+}
+
+// validLatLon rejects 0,0 and out of range coordinates NJ TRANSIT sometimes reports
+func validLatLon(lat, lon float64) bool {
+	return !(lat == 0 && lon == 0) && lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+func translatedString(text string) *gtfs.TranslatedString {
+	return &gtfs.TranslatedString{
+		Translation: []*gtfs.TranslatedString_Translation{
+			{Text: proto.String(text), Language: proto.String("en")},
+		},
+	}
+}
+
+func isCancelled(status string) bool {
+	return strings.Contains(strings.ToUpper(status), "CANCEL")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
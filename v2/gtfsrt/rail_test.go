@@ -0,0 +1,91 @@
+package gtfsrt
+
+import (
+	"encoding/xml"
+	"os"
+	"testing"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadTrainSchedule19Rec(t *testing.T) *njtv1.GetTrainSchedule19RecResponse {
+	b, err := os.ReadFile("testdata/trainschedule19rec_NP.xml")
+	assert.NoError(t, err)
+
+	resp := &njtv1.GetTrainSchedule19RecResponse{}
+	assert.NoError(t, xml.Unmarshal(b, resp))
+	return resp
+}
+
+func TestRailConverter(t *testing.T) {
+	now := time.Date(2019, 5, 24, 9, 22, 0, 0, time.UTC)
+	c := NewRailConverter(time.UTC, map[string]string{"3837": "block-3837"}, map[string]string{"NP": "107"})
+	c.AddTrainSchedule19Rec(loadTrainSchedule19Rec(t))
+	c.AddStationMessage(&njtv1.GetStationMessageResponse{
+		TwoChar:       "NY",
+		BannerMessage: "Expect 15 minute delays &amp; crowding",
+		Items: []*njtv1.GetStationMessageResponseItem{
+			{TrainID: "3837", SchedDepDate: "24-May-2019 09:40:00 AM", SecLate: "180", Status: "Late"},
+		},
+	})
+	c.AddStationMessage(&njtv1.GetStationMessageResponse{TwoChar: "NP", BannerMessage: "Expect 15 minute delays &amp; crowding"})
+
+	// entries without station code are skipped
+	c.AddTrainSchedule19Rec(&njtv1.GetTrainSchedule19RecResponse{Items: []*njtv1.GetTrainSchedule19RecResponseItem{
+		{TrainID: "9999", SchedDepDate: "24-May-2019 09:40:00 AM"},
+	}})
+	c.AddStationMessage(&njtv1.GetStationMessageResponse{TwoChar: " ", BannerMessage: "Ignored"})
+
+	tripUpdates := c.TripUpdates(now)
+	assert.Equal(t, []string{"3837", "3841", "3845"}, entityIDs(tripUpdates))
+
+	update := tripUpdates.GetEntity()[0].GetTripUpdate()
+	assert.Equal(t, "block-3837", update.GetTrip().GetTripId())
+	assert.Equal(t, int32(120), update.GetDelay()) // next upcoming stop
+	if assert.Len(t, update.GetStopTimeUpdate(), 2) {
+		first := update.GetStopTimeUpdate()[0]
+		assert.Equal(t, "107", first.GetStopId())
+		assert.Equal(t, int32(120), first.GetDeparture().GetDelay())
+		assert.Equal(t, time.Date(2019, 5, 24, 9, 24, 30, 0, time.UTC).Unix(), first.GetDeparture().GetTime())
+		assert.Equal(t, "NY", update.GetStopTimeUpdate()[1].GetStopId())
+	}
+
+	cancelled := tripUpdates.GetEntity()[1].GetTripUpdate()
+	assert.Equal(t, gtfs.TripDescriptor_CANCELED, cancelled.GetTrip().GetScheduleRelationship())
+	assert.Empty(t, cancelled.GetStopTimeUpdate())
+
+	// missing SEC_LATE is no data rather than on time
+	noData := tripUpdates.GetEntity()[2].GetTripUpdate()
+	if assert.Len(t, noData.GetStopTimeUpdate(), 1) {
+		assert.Equal(t, gtfs.TripUpdate_StopTimeUpdate_NO_DATA, noData.GetStopTimeUpdate()[0].GetScheduleRelationship())
+		assert.Nil(t, noData.GetStopTimeUpdate()[0].GetDeparture())
+	}
+	assert.Nil(t, noData.Delay)
+
+	// after departure from NP the delay is taken from NY
+	update = c.TripUpdates(now.Add(5 * time.Minute)).GetEntity()[0].GetTripUpdate()
+	assert.Equal(t, int32(180), update.GetDelay())
+
+	// 0,0 and out of range coordinates are not positions
+	positions := c.VehiclePositions(now)
+	if assert.Equal(t, []string{"3837"}, entityIDs(positions)) {
+		vehicle := positions.GetEntity()[0].GetVehicle()
+		assert.InDelta(t, 40.7347, vehicle.GetPosition().GetLatitude(), 1e-4)
+		assert.InDelta(t, -74.1644, vehicle.GetPosition().GetLongitude(), 1e-4)
+		assert.Equal(t, uint64(time.Date(2019, 5, 24, 9, 21, 45, 0, time.UTC).Unix()), vehicle.GetTimestamp())
+		assert.Equal(t, "107", vehicle.GetStopId())
+	}
+
+	alerts := c.Alerts(now)
+	if assert.Len(t, alerts.GetEntity(), 1) {
+		alert := alerts.GetEntity()[0].GetAlert()
+		assert.Equal(t, "Expect 15 minute delays & crowding", alert.GetHeaderText().GetTranslation()[0].GetText())
+		assert.Len(t, alert.GetInformedEntity(), 2)
+	}
+
+	c.Reset()
+	assert.Empty(t, c.TripUpdates(now).GetEntity())
+}
@@ -0,0 +1,300 @@
+package gtfsrt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	"github.com/errornil/njtransit/v2/mapmatch"
+	gtfs "github.com/errornil/transit_realtime"
+)
+
+// DefaultOffShapeThresholdMeters is the distance from the trip shape
+// after which vehicle position is reported by Validator
+const DefaultOffShapeThresholdMeters = 500
+
+// Severity of validation Finding
+type Severity string
+
+// Severities
+const (
+	SeverityError   Severity = "ERROR"
+	SeverityWarning Severity = "WARNING"
+)
+
+// Validation rule IDs
+const (
+	RuleMissingVersion          = "missing_gtfs_realtime_version"
+	RuleMissingTimestamp        = "missing_header_timestamp"
+	RuleFutureTimestamp         = "timestamp_in_future"
+	RuleMissingEntityID         = "missing_entity_id"
+	RuleDuplicateEntityID       = "duplicate_entity_id"
+	RuleEmptyEntity             = "empty_entity"
+	RuleDeletedInFullDataset    = "is_deleted_in_full_dataset"
+	RuleMissingTrip             = "missing_trip_descriptor"
+	RuleMissingStopReference    = "missing_stop_reference"
+	RuleUnorderedStopSequence   = "unordered_stop_sequence"
+	RuleUnorderedStopTimes      = "unordered_stop_times"
+	RuleInvalidPosition         = "invalid_position"
+	RuleMissingInformedEntity   = "missing_informed_entity"
+	RuleMissingAlertText        = "missing_alert_header_text"
+	RuleUnknownTripID           = "unknown_trip_id"
+	RuleUnknownRouteID          = "unknown_route_id"
+	RuleUnknownStopID           = "unknown_stop_id"
+	RuleUnknownStopSequence     = "unknown_stop_sequence"
+	RuleStopSequenceMismatch    = "stop_sequence_stop_id_mismatch"
+	RuleVehicleFarFromTripShape = "vehicle_far_from_trip_shape"
+)
+
+// maxClockSkew is allowed difference between feed timestamps and validation time
+const maxClockSkew = time.Minute
+
+// Finding is a single validation problem
+type Finding struct {
+	RuleID   string   `json:"rule_id"`
+	Severity Severity `json:"severity"`
+	EntityID string   `json:"entity_id,omitempty"` // empty for header findings
+	Message  string   `json:"message"`
+}
+
+// Validator checks GTFS-RT feeds against the specification
+// and, optionally, against GTFS static feed. Validator is safe for concurrent use.
+type Validator struct {
+	offShapeThreshold float64
+
+	trips     map[string]*gtfsstatic.Trip
+	routes    map[string]*gtfsstatic.Route
+	stops     map[string]*gtfsstatic.Stop
+	stopTimes map[string][]gtfsstatic.StopTime
+	matcher   *mapmatch.Matcher
+}
+
+// NewValidator creates new Validator, static can be nil to only check the specification rules.
+// offShapeThreshold is in meters, DefaultOffShapeThresholdMeters is used when it's not positive.
+func NewValidator(static *gtfsstatic.Feed, offShapeThreshold float64) *Validator {
+	if offShapeThreshold <= 0 {
+		offShapeThreshold = DefaultOffShapeThresholdMeters
+	}
+
+	v := &Validator{offShapeThreshold: offShapeThreshold}
+	if static != nil {
+		v.trips = static.TripByID()
+		v.routes = static.RouteByID()
+		v.stops = static.StopByID()
+		v.stopTimes = static.StopTimesByTrip()
+		v.matcher = mapmatch.NewMatcher(static, offShapeThreshold)
+	}
+	return v
+}
+
+// Validate returns all findings for the feed, now is used to detect timestamps in the future
+func (v *Validator) Validate(feed *gtfs.FeedMessage, now time.Time) []Finding {
+	var findings []Finding
+	add := func(ruleID string, severity Severity, entityID, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			RuleID:   ruleID,
+			Severity: severity,
+			EntityID: entityID,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	header := feed.GetHeader()
+	if header.GetGtfsRealtimeVersion() == "" {
+		add(RuleMissingVersion, SeverityError, "", "header has no gtfs_realtime_version")
+	}
+	if header.GetTimestamp() == 0 {
+		add(RuleMissingTimestamp, SeverityError, "", "header has no timestamp")
+	} else if isFuture(header.GetTimestamp(), now) {
+		add(RuleFutureTimestamp, SeverityWarning, "", "header timestamp %d is in the future", header.GetTimestamp())
+	}
+	full := header.GetIncrementality() == gtfs.FeedHeader_FULL_DATASET
+
+	ids := map[string]bool{}
+	for _, entity := range feed.GetEntity() {
+		id := entity.GetId()
+		if id == "" {
+			add(RuleMissingEntityID, SeverityError, "", "entity has no id")
+		} else if ids[id] {
+			add(RuleDuplicateEntityID, SeverityError, id, "entity id is not unique")
+		}
+		ids[id] = true
+
+		if entity.GetIsDeleted() {
+			if full {
+				add(RuleDeletedInFullDataset, SeverityError, id, "is_deleted is only allowed in DIFFERENTIAL feeds")
+			}
+			continue
+		}
+
+		if entity.GetTripUpdate() == nil && entity.GetVehicle() == nil && entity.GetAlert() == nil {
+			add(RuleEmptyEntity, SeverityError, id, "entity has no trip_update, vehicle or alert")
+		}
+		if entity.GetTripUpdate() != nil {
+			v.validateTripUpdate(entity.GetTripUpdate(), id, now, add)
+		}
+		if entity.GetVehicle() != nil {
+			v.validateVehicle(entity.GetVehicle(), id, now, add)
+		}
+		if entity.GetAlert() != nil {
+			v.validateAlert(entity.GetAlert(), id, add)
+		}
+	}
+
+	return findings
+}
+
+type addFunc func(ruleID string, severity Severity, entityID, format string, args ...interface{})
+
+func (v *Validator) validateTripUpdate(update *gtfs.TripUpdate, id string, now time.Time, add addFunc) {
+	if update.GetTrip() == nil {
+		add(RuleMissingTrip, SeverityError, id, "trip_update has no trip")
+		return
+	}
+	if isFuture(update.GetTimestamp(), now) {
+		add(RuleFutureTimestamp, SeverityWarning, id, "trip_update timestamp %d is in the future", update.GetTimestamp())
+	}
+	v.validateTrip(update.GetTrip(), id, add)
+
+	var (
+		previousSequence uint32
+		previousTime     int64
+	)
+	for i, stu := range update.GetStopTimeUpdate() {
+		if stu.StopSequence == nil && stu.StopId == nil {
+			add(RuleMissingStopReference, SeverityError, id, "stop_time_update #%d has no stop_sequence or stop_id", i)
+		}
+		if stu.StopSequence != nil {
+			if i > 0 && stu.GetStopSequence() <= previousSequence {
+				add(RuleUnorderedStopSequence, SeverityError, id,
+					"stop_sequence %d is not greater than previous %d", stu.GetStopSequence(), previousSequence)
+			}
+			previousSequence = stu.GetStopSequence()
+		}
+
+		for _, event := range []*gtfs.TripUpdate_StopTimeEvent{stu.GetArrival(), stu.GetDeparture()} {
+			if event.GetTime() == 0 {
+				continue
+			}
+			if event.GetTime() < previousTime {
+				add(RuleUnorderedStopTimes, SeverityError, id,
+					"stop_time_update #%d time %d is before previous time %d", i, event.GetTime(), previousTime)
+			}
+			previousTime = event.GetTime()
+		}
+
+		v.validateStop(update.GetTrip().GetTripId(), stu, id, add)
+	}
+}
+
+func (v *Validator) validateVehicle(vehicle *gtfs.VehiclePosition, id string, now time.Time, add addFunc) {
+	if isFuture(vehicle.GetTimestamp(), now) {
+		add(RuleFutureTimestamp, SeverityWarning, id, "vehicle timestamp %d is in the future", vehicle.GetTimestamp())
+	}
+	if vehicle.GetTrip() != nil {
+		v.validateTrip(vehicle.GetTrip(), id, add)
+	}
+	if vehicle.GetStopId() != "" && v.stops != nil {
+		if _, ok := v.stops[vehicle.GetStopId()]; !ok {
+			add(RuleUnknownStopID, SeverityError, id, "stop_id %q is not in GTFS", vehicle.GetStopId())
+		}
+	}
+
+	position := vehicle.GetPosition()
+	if position == nil {
+		return
+	}
+	lat, lon := position.GetLatitude(), position.GetLongitude()
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 || lat == 0 && lon == 0 {
+		add(RuleInvalidPosition, SeverityError, id, "invalid position %f,%f", lat, lon)
+		return
+	}
+
+	if v.matcher == nil || v.trips[vehicle.GetTrip().GetTripId()] == nil {
+		return
+	}
+	progress, err := v.matcher.MatchVehiclePosition(vehicle)
+	if err == nil && progress.OffRoute {
+		add(RuleVehicleFarFromTripShape, SeverityWarning, id,
+			"vehicle is %.0fm away from trip %q shape", progress.DistanceFromShapeMeters, progress.TripID)
+	}
+}
+
+func (v *Validator) validateAlert(alert *gtfs.Alert, id string, add addFunc) {
+	if len(alert.GetInformedEntity()) == 0 {
+		add(RuleMissingInformedEntity, SeverityError, id, "alert has no informed_entity")
+	}
+	if len(alert.GetHeaderText().GetTranslation()) == 0 {
+		add(RuleMissingAlertText, SeverityWarning, id, "alert has no header_text")
+	}
+
+	for _, selector := range alert.GetInformedEntity() {
+		if selector.GetTrip() != nil {
+			v.validateTrip(selector.GetTrip(), id, add)
+		}
+		if selector.GetRouteId() != "" && v.routes != nil {
+			if _, ok := v.routes[selector.GetRouteId()]; !ok {
+				add(RuleUnknownRouteID, SeverityError, id, "route_id %q is not in GTFS", selector.GetRouteId())
+			}
+		}
+		if selector.GetStopId() != "" && v.stops != nil {
+			if _, ok := v.stops[selector.GetStopId()]; !ok {
+				add(RuleUnknownStopID, SeverityError, id, "stop_id %q is not in GTFS", selector.GetStopId())
+			}
+		}
+	}
+}
+
+// validateTrip checks trip descriptor against GTFS static,
+// added trips are not expected to be in GTFS
+func (v *Validator) validateTrip(trip *gtfs.TripDescriptor, id string, add addFunc) {
+	if v.trips == nil {
+		return
+	}
+
+	relationship := trip.GetScheduleRelationship()
+	if trip.GetTripId() != "" && relationship != gtfs.TripDescriptor_ADDED && relationship != gtfs.TripDescriptor_UNSCHEDULED {
+		if _, ok := v.trips[trip.GetTripId()]; !ok {
+			add(RuleUnknownTripID, SeverityError, id, "trip_id %q is not in GTFS", trip.GetTripId())
+		}
+	}
+	if trip.GetRouteId() != "" {
+		if _, ok := v.routes[trip.GetRouteId()]; !ok {
+			add(RuleUnknownRouteID, SeverityError, id, "route_id %q is not in GTFS", trip.GetRouteId())
+		}
+	}
+}
+
+// validateStop checks stop_id and stop_sequence against trip stop_times
+func (v *Validator) validateStop(tripID string, stu *gtfs.TripUpdate_StopTimeUpdate, id string, add addFunc) {
+	if v.stops == nil {
+		return
+	}
+
+	if stu.StopId != nil {
+		if _, ok := v.stops[stu.GetStopId()]; !ok {
+			add(RuleUnknownStopID, SeverityError, id, "stop_id %q is not in GTFS", stu.GetStopId())
+		}
+	}
+
+	stopTimes, ok := v.stopTimes[tripID]
+	if !ok || stu.StopSequence == nil {
+		return
+	}
+
+	for _, st := range stopTimes {
+		if st.StopSequence != int(stu.GetStopSequence()) {
+			continue
+		}
+		if stu.StopId != nil && st.StopID != stu.GetStopId() {
+			add(RuleStopSequenceMismatch, SeverityError, id,
+				"stop_sequence %d of trip %q is stop %q, not %q", st.StopSequence, tripID, st.StopID, stu.GetStopId())
+		}
+		return
+	}
+	add(RuleUnknownStopSequence, SeverityError, id, "stop_sequence %d is not in trip %q", stu.GetStopSequence(), tripID)
+}
+
+func isFuture(timestamp uint64, now time.Time) bool {
+	return timestamp != 0 && time.Unix(int64(timestamp), 0).After(now.Add(maxClockSkew))
+}
@@ -0,0 +1,87 @@
+package gtfsrt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestValidate(t *testing.T) {
+	static := &gtfsstatic.Feed{
+		Stops: []gtfsstatic.Stop{
+			{ID: "A", Lat: 40.7, Lon: -74.2},
+			{ID: "B", Lat: 40.7, Lon: -74.1},
+		},
+		Routes: []gtfsstatic.Route{{ID: "10"}},
+		Trips:  []gtfsstatic.Trip{{ID: "T1", RouteID: "10", ShapeID: "S1"}},
+		StopTimes: []gtfsstatic.StopTime{
+			{TripID: "T1", StopID: "A", StopSequence: 1},
+			{TripID: "T1", StopID: "B", StopSequence: 2},
+		},
+		Shapes: []gtfsstatic.Shape{{ID: "S1", Points: []gtfsstatic.ShapePoint{
+			{Lat: 40.7, Lon: -74.2, Sequence: 1},
+			{Lat: 40.7, Lon: -74.1, Sequence: 2},
+		}}},
+	}
+	now := time.Unix(1727700000, 0)
+
+	feed := NewFeedMessage([]*gtfs.FeedEntity{
+		{
+			Id: proto.String("valid"),
+			TripUpdate: &gtfs.TripUpdate{
+				Trip: &gtfs.TripDescriptor{TripId: proto.String("T1"), RouteId: proto.String("10")},
+				StopTimeUpdate: []*gtfs.TripUpdate_StopTimeUpdate{
+					{StopSequence: proto.Uint32(1), StopId: proto.String("A"), Departure: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(100)}},
+					{StopSequence: proto.Uint32(2), StopId: proto.String("B"), Arrival: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(200)}},
+				},
+			},
+		},
+		{
+			Id: proto.String("invalid"),
+			TripUpdate: &gtfs.TripUpdate{
+				Trip: &gtfs.TripDescriptor{TripId: proto.String("T2")},
+				StopTimeUpdate: []*gtfs.TripUpdate_StopTimeUpdate{
+					{StopSequence: proto.Uint32(2), StopId: proto.String("C"), Arrival: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(200)}},
+					{StopSequence: proto.Uint32(1), Arrival: &gtfs.TripUpdate_StopTimeEvent{Time: proto.Int64(100)}},
+				},
+			},
+		},
+		{
+			Id: proto.String("bus"),
+			Vehicle: &gtfs.VehiclePosition{
+				Trip:      &gtfs.TripDescriptor{TripId: proto.String("T1")},
+				Position:  &gtfs.Position{Latitude: proto.Float32(40.8), Longitude: proto.Float32(-74.15)},
+				Timestamp: proto.Uint64(uint64(now.Add(time.Hour).Unix())),
+			},
+		},
+		{Id: proto.String("bus")},
+	}, now)
+
+	findings := NewValidator(static, 0).Validate(feed, now)
+
+	var rules []string
+	for _, f := range findings {
+		assert.NotEqual(t, "valid", f.EntityID, f.Message)
+		rules = append(rules, f.EntityID+":"+f.RuleID)
+	}
+	assert.ElementsMatch(t, []string{
+		"invalid:" + RuleUnknownTripID,
+		"invalid:" + RuleUnknownStopID,
+		"invalid:" + RuleUnorderedStopSequence,
+		"invalid:" + RuleUnorderedStopTimes,
+		"bus:" + RuleFutureTimestamp,
+		"bus:" + RuleVehicleFarFromTripShape,
+		"bus:" + RuleDuplicateEntityID,
+		"bus:" + RuleEmptyEntity,
+	}, rules)
+
+	findings = NewValidator(nil, 0).Validate(&gtfs.FeedMessage{}, now)
+	assert.Equal(t, []Finding{
+		{RuleID: RuleMissingVersion, Severity: SeverityError, Message: "header has no gtfs_realtime_version"},
+		{RuleID: RuleMissingTimestamp, Severity: SeverityError, Message: "header has no timestamp"},
+	}, findings)
+}
@@ -0,0 +1,579 @@
+package gtfsstatic
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NoTime is used for StopTime arrival and departure times that are not set
+const NoTime time.Duration = -1
+
+// Feed holds parsed content of GTFS static archive,
+// as returned by BusClient.GetGTFS
+type Feed struct {
+	Stops     []Stop
+	Routes    []Route
+	Trips     []Trip
+	StopTimes []StopTime // ordered by trip_id and stop_sequence
+	Shapes    []Shape
+
+	Calendars     []Calendar
+	CalendarDates []CalendarDate
+	FeedInfo      *FeedInfo // nil if feed_info.txt is missing
+}
+
+// Stop represents a row in stops.txt
+type Stop struct {
+	ID            string  // stop_id
+	Code          string  // stop_code
+	Name          string  // stop_name
+	Desc          string  // stop_desc
+	Lat           float64 // stop_lat
+	Lon           float64 // stop_lon
+	ZoneID        string  // zone_id
+	LocationType  int     // location_type, 0 - stop, 1 - station
+	ParentStation string  // parent_station
+}
+
+// Route represents a row in routes.txt
+type Route struct {
+	ID        string // route_id
+	AgencyID  string // agency_id
+	ShortName string // route_short_name
+	LongName  string // route_long_name
+	Type      int    // route_type
+	Color     string // route_color
+}
+
+// Trip represents a row in trips.txt
+type Trip struct {
+	ID          string // trip_id
+	RouteID     string // route_id
+	ServiceID   string // service_id
+	Headsign    string // trip_headsign
+	DirectionID int    // direction_id
+	BlockID     string // block_id
+	ShapeID     string // shape_id
+}
+
+// StopTime represents a row in stop_times.txt
+type StopTime struct {
+	TripID            string        // trip_id
+	ArrivalTime       time.Duration // arrival_time since service day noon minus 12h, NoTime if empty
+	DepartureTime     time.Duration // departure_time since service day noon minus 12h, NoTime if empty
+	StopID            string        // stop_id
+	StopSequence      int           // stop_sequence
+	ShapeDistTraveled float64       // shape_dist_traveled, in units of the feed
+}
+
+// Shape is a set of rows in shapes.txt with the same shape_id,
+// points are ordered by shape_pt_sequence
+type Shape struct {
+	ID     string
+	Points []ShapePoint
+}
+
+// ShapePoint represents a row in shapes.txt
+type ShapePoint struct {
+	Lat          float64 // shape_pt_lat
+	Lon          float64 // shape_pt_lon
+	Sequence     int     // shape_pt_sequence
+	DistTraveled float64 // shape_dist_traveled, in units of the feed
+}
+
+// Calendar represents a row in calendar.txt
+type Calendar struct {
+	ServiceID string  // service_id
+	Weekdays  [7]bool // monday..sunday columns indexed by time.Weekday
+	StartDate string  // start_date, YYYYMMDD
+	EndDate   string  // end_date, YYYYMMDD
+}
+
+// CalendarDate represents a row in calendar_dates.txt
+type CalendarDate struct {
+	ServiceID     string // service_id
+	Date          string // date, YYYYMMDD
+	ExceptionType int    // exception_type, 1 - service added, 2 - service removed
+}
+
+// FeedInfo represents feed_info.txt
+type FeedInfo struct {
+	PublisherName string // feed_publisher_name
+	PublisherURL  string // feed_publisher_url
+	Lang          string // feed_lang
+	StartDate     string // feed_start_date, YYYYMMDD
+	EndDate       string // feed_end_date, YYYYMMDD
+	Version       string // feed_version
+}
+
+// Parse parses GTFS static zip archive
+func Parse(b []byte) (*Feed, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("open zip: %v", err)
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		// some producers put files into a top level directory
+		name := f.Name
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		files[name] = f
+	}
+
+	feed := &Feed{}
+
+	err = readFile(files, "stops.txt", true, func(r record) error {
+		stop, err := parseStop(r)
+		if err != nil {
+			return err
+		}
+		feed.Stops = append(feed.Stops, stop)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readFile(files, "routes.txt", false, func(r record) error {
+		route, err := parseRoute(r)
+		if err != nil {
+			return err
+		}
+		feed.Routes = append(feed.Routes, route)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readFile(files, "trips.txt", false, func(r record) error {
+		trip, err := parseTrip(r)
+		if err != nil {
+			return err
+		}
+		feed.Trips = append(feed.Trips, trip)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readFile(files, "stop_times.txt", false, func(r record) error {
+		stopTime, err := parseStopTime(r)
+		if err != nil {
+			return err
+		}
+		feed.StopTimes = append(feed.StopTimes, stopTime)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(feed.StopTimes, func(i, j int) bool {
+		if feed.StopTimes[i].TripID == feed.StopTimes[j].TripID {
+			return feed.StopTimes[i].StopSequence < feed.StopTimes[j].StopSequence
+		}
+		return feed.StopTimes[i].TripID < feed.StopTimes[j].TripID
+	})
+
+	shapes := map[string]int{}
+	err = readFile(files, "shapes.txt", false, func(r record) error {
+		id, point, err := parseShapePoint(r)
+		if err != nil {
+			return err
+		}
+		i, ok := shapes[id]
+		if !ok {
+			i = len(feed.Shapes)
+			shapes[id] = i
+			feed.Shapes = append(feed.Shapes, Shape{ID: id})
+		}
+		feed.Shapes[i].Points = append(feed.Shapes[i].Points, point)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, shape := range feed.Shapes {
+		points := shape.Points
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].Sequence < points[j].Sequence
+		})
+	}
+
+	err = readFile(files, "calendar.txt", false, func(r record) error {
+		calendar, err := parseCalendar(r)
+		if err != nil {
+			return err
+		}
+		feed.Calendars = append(feed.Calendars, calendar)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readFile(files, "calendar_dates.txt", false, func(r record) error {
+		exceptionType, err := r.int("exception_type")
+		if err != nil {
+			return err
+		}
+		feed.CalendarDates = append(feed.CalendarDates, CalendarDate{
+			ServiceID:     r.get("service_id"),
+			Date:          r.get("date"),
+			ExceptionType: exceptionType,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readFile(files, "feed_info.txt", false, func(r record) error {
+		if feed.FeedInfo != nil {
+			return nil
+		}
+		feed.FeedInfo = &FeedInfo{
+			PublisherName: r.get("feed_publisher_name"),
+			PublisherURL:  r.get("feed_publisher_url"),
+			Lang:          r.get("feed_lang"),
+			StartDate:     r.get("feed_start_date"),
+			EndDate:       r.get("feed_end_date"),
+			Version:       r.get("feed_version"),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// StopByID returns map from stop_id to Stop
+func (f *Feed) StopByID() map[string]*Stop {
+	m := make(map[string]*Stop, len(f.Stops))
+	for i := range f.Stops {
+		m[f.Stops[i].ID] = &f.Stops[i]
+	}
+	return m
+}
+
+// RouteByID returns map from route_id to Route
+func (f *Feed) RouteByID() map[string]*Route {
+	m := make(map[string]*Route, len(f.Routes))
+	for i := range f.Routes {
+		m[f.Routes[i].ID] = &f.Routes[i]
+	}
+	return m
+}
+
+// TripByID returns map from trip_id to Trip
+func (f *Feed) TripByID() map[string]*Trip {
+	m := make(map[string]*Trip, len(f.Trips))
+	for i := range f.Trips {
+		m[f.Trips[i].ID] = &f.Trips[i]
+	}
+	return m
+}
+
+// ShapeByID returns map from shape_id to Shape
+func (f *Feed) ShapeByID() map[string]*Shape {
+	m := make(map[string]*Shape, len(f.Shapes))
+	for i := range f.Shapes {
+		m[f.Shapes[i].ID] = &f.Shapes[i]
+	}
+	return m
+}
+
+// StopTimesByTrip returns map from trip_id to its stop times ordered by stop_sequence,
+// slices share memory with Feed.StopTimes
+func (f *Feed) StopTimesByTrip() map[string][]StopTime {
+	m := map[string][]StopTime{}
+	start := 0
+	for i := 1; i <= len(f.StopTimes); i++ {
+		if i == len(f.StopTimes) || f.StopTimes[i].TripID != f.StopTimes[start].TripID {
+			m[f.StopTimes[start].TripID] = f.StopTimes[start:i:i]
+			start = i
+		}
+	}
+	return m
+}
+
+func parseStop(r record) (Stop, error) {
+	lat, err := r.float("stop_lat")
+	if err != nil {
+		return Stop{}, err
+	}
+	lon, err := r.float("stop_lon")
+	if err != nil {
+		return Stop{}, err
+	}
+	locationType, err := r.int("location_type")
+	if err != nil {
+		return Stop{}, err
+	}
+
+	return Stop{
+		ID:            r.get("stop_id"),
+		Code:          r.get("stop_code"),
+		Name:          r.get("stop_name"),
+		Desc:          r.get("stop_desc"),
+		Lat:           lat,
+		Lon:           lon,
+		ZoneID:        r.get("zone_id"),
+		LocationType:  locationType,
+		ParentStation: r.get("parent_station"),
+	}, nil
+}
+
+func parseRoute(r record) (Route, error) {
+	routeType, err := r.int("route_type")
+	if err != nil {
+		return Route{}, err
+	}
+
+	return Route{
+		ID:        r.get("route_id"),
+		AgencyID:  r.get("agency_id"),
+		ShortName: r.get("route_short_name"),
+		LongName:  r.get("route_long_name"),
+		Type:      routeType,
+		Color:     r.get("route_color"),
+	}, nil
+}
+
+func parseTrip(r record) (Trip, error) {
+	directionID, err := r.int("direction_id")
+	if err != nil {
+		return Trip{}, err
+	}
+
+	return Trip{
+		ID:          r.get("trip_id"),
+		RouteID:     r.get("route_id"),
+		ServiceID:   r.get("service_id"),
+		Headsign:    r.get("trip_headsign"),
+		DirectionID: directionID,
+		BlockID:     r.get("block_id"),
+		ShapeID:     r.get("shape_id"),
+	}, nil
+}
+
+func parseStopTime(r record) (StopTime, error) {
+	arrival, err := r.time("arrival_time")
+	if err != nil {
+		return StopTime{}, err
+	}
+	departure, err := r.time("departure_time")
+	if err != nil {
+		return StopTime{}, err
+	}
+	sequence, err := r.int("stop_sequence")
+	if err != nil {
+		return StopTime{}, err
+	}
+	dist, err := r.float("shape_dist_traveled")
+	if err != nil {
+		return StopTime{}, err
+	}
+
+	return StopTime{
+		TripID:            r.get("trip_id"),
+		ArrivalTime:       arrival,
+		DepartureTime:     departure,
+		StopID:            r.get("stop_id"),
+		StopSequence:      sequence,
+		ShapeDistTraveled: dist,
+	}, nil
+}
+
+func parseCalendar(r record) (Calendar, error) {
+	calendar := Calendar{
+		ServiceID: r.get("service_id"),
+		StartDate: r.get("start_date"),
+		EndDate:   r.get("end_date"),
+	}
+
+	days := map[time.Weekday]string{
+		time.Monday:    "monday",
+		time.Tuesday:   "tuesday",
+		time.Wednesday: "wednesday",
+		time.Thursday:  "thursday",
+		time.Friday:    "friday",
+		time.Saturday:  "saturday",
+		time.Sunday:    "sunday",
+	}
+	for day, column := range days {
+		v, err := r.int(column)
+		if err != nil {
+			return Calendar{}, err
+		}
+		calendar.Weekdays[day] = v == 1
+	}
+
+	return calendar, nil
+}
+
+func parseShapePoint(r record) (string, ShapePoint, error) {
+	lat, err := r.float("shape_pt_lat")
+	if err != nil {
+		return "", ShapePoint{}, err
+	}
+	lon, err := r.float("shape_pt_lon")
+	if err != nil {
+		return "", ShapePoint{}, err
+	}
+	sequence, err := r.int("shape_pt_sequence")
+	if err != nil {
+		return "", ShapePoint{}, err
+	}
+	dist, err := r.float("shape_dist_traveled")
+	if err != nil {
+		return "", ShapePoint{}, err
+	}
+
+	return r.get("shape_id"), ShapePoint{
+		Lat:          lat,
+		Lon:          lon,
+		Sequence:     sequence,
+		DistTraveled: dist,
+	}, nil
+}
+
+// record is a single CSV row with access to columns by header name
+type record struct {
+	file    string
+	line    int
+	columns map[string]int
+	values  []string
+}
+
+func (r record) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.values) {
+		return ""
+	}
+	return strings.TrimSpace(r.values[i])
+}
+
+func (r record) float(column string) (float64, error) {
+	v := r.get(column)
+	if v == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%d: parse %s %q: %v", r.file, r.line, column, v, err)
+	}
+	return f, nil
+}
+
+func (r record) int(column string) (int, error) {
+	v := r.get(column)
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%d: parse %s %q: %v", r.file, r.line, column, v, err)
+	}
+	return i, nil
+}
+
+// time parses GTFS time (HH:MM:SS, hours can exceed 24)
+func (r record) time(column string) (time.Duration, error) {
+	v := r.get(column)
+	if v == "" {
+		return NoTime, nil
+	}
+	d, err := ParseTime(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s:%d: parse %s: %v", r.file, r.line, column, err)
+	}
+	return d, nil
+}
+
+// ParseTime parses GTFS time in HH:MM:SS format,
+// returns duration since service day noon minus 12h
+func ParseTime(v string) (time.Duration, error) {
+	parts := strings.Split(v, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q", v)
+	}
+	var values [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid time %q", v)
+		}
+		values[i] = n
+	}
+	return time.Duration(values[0])*time.Hour +
+		time.Duration(values[1])*time.Minute +
+		time.Duration(values[2])*time.Second, nil
+}
+
+func readFile(files map[string]*zip.File, name string, required bool, fn func(r record) error) error {
+	f, ok := files[name]
+	if !ok {
+		if required {
+			return fmt.Errorf("missing %s", name)
+		}
+		return nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("open %s: %v", name, err)
+	}
+	defer rc.Close()
+
+	reader := csv.NewReader(rc)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = false
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s header: %v", name, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		if i == 0 {
+			h = strings.TrimPrefix(h, "\ufeff")
+		}
+		columns[strings.TrimSpace(h)] = i
+	}
+
+	line := 1
+	for {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		line++
+		if err != nil {
+			return fmt.Errorf("read %s: %v", name, err)
+		}
+
+		err = fn(record{file: name, line: line, columns: columns, values: values})
+		if err != nil {
+			return err
+		}
+	}
+}
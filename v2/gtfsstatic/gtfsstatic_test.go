@@ -0,0 +1,73 @@
+package gtfsstatic
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func buildArchive(t *testing.T, files map[string]string) []byte {
+	b := &bytes.Buffer{}
+	w := zip.NewWriter(b)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	feed, err := Parse(buildArchive(t, map[string]string{
+		"stops.txt": "\ufeffstop_id,stop_code,stop_name,stop_lat,stop_lon\n" +
+			"1,20001,\"MAIN ST, 1ST AVE\",40.7,-74.1\n",
+		"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n" +
+			"T1,25:01:02,,1,2\n" +
+			"T1,24:59:00,24:59:00,1,1\n",
+		"shapes.txt": "shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\n" +
+			"S1,40.71,-74.1,2\n" +
+			"S1,40.7,-74.1,1\n",
+		"feed_info.txt": "feed_publisher_name,feed_publisher_url,feed_lang,feed_version\n" +
+			"NJ TRANSIT,https://www.njtransit.com,en,2024-09-30\n",
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Stop{{ID: "1", Code: "20001", Name: "MAIN ST, 1ST AVE", Lat: 40.7, Lon: -74.1}}, feed.Stops)
+	assert.Equal(t, []StopTime{
+		{TripID: "T1", ArrivalTime: 24*time.Hour + 59*time.Minute, DepartureTime: 24*time.Hour + 59*time.Minute, StopID: "1", StopSequence: 1},
+		{TripID: "T1", ArrivalTime: 25*time.Hour + time.Minute + 2*time.Second, DepartureTime: NoTime, StopID: "1", StopSequence: 2},
+	}, feed.StopTimes)
+	assert.Equal(t, []Shape{{ID: "S1", Points: []ShapePoint{
+		{Lat: 40.7, Lon: -74.1, Sequence: 1},
+		{Lat: 40.71, Lon: -74.1, Sequence: 2},
+	}}}, feed.Shapes)
+	assert.Equal(t, "2024-09-30", feed.FeedInfo.Version)
+
+	_, err = Parse(buildArchive(t, map[string]string{"routes.txt": "route_id\n"}))
+	assert.EqualError(t, err, "missing stops.txt")
+}
+
+func TestParseTime(t *testing.T) {
+	d, err := ParseTime("25:01:02")
+	assert.NoError(t, err)
+	assert.Equal(t, 25*time.Hour+time.Minute+2*time.Second, d)
+
+	d, err = ParseTime("8:05:00")
+	assert.NoError(t, err)
+	assert.Equal(t, 8*time.Hour+5*time.Minute, d)
+
+	for _, v := range []string{"", "08:05", "08:xx:00", "-1:00:00"} {
+		_, err = ParseTime(v)
+		assert.Error(t, err, v)
+	}
+}
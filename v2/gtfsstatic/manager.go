@@ -0,0 +1,222 @@
+package gtfsstatic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMinDownloadInterval keeps Manager within getGTFS daily limit
+const DefaultMinDownloadInterval = 3 * time.Hour
+
+// DefaultRetryInterval is the delay before retrying a failed download,
+// doubled after each failure up to the refresh interval
+const DefaultRetryInterval = time.Minute
+
+// ErrDownloadTooSoon is returned by Manager.Refresh when previous download
+// was less than min download interval ago, or failed download is retried before its backoff
+var ErrDownloadTooSoon = errors.New("GTFS was downloaded too recently")
+
+// GTFSGetter downloads GTFS static archive, implemented by BusClient
+type GTFSGetter interface {
+	GetGTFS() ([]byte, error)
+}
+
+// Manager periodically downloads GTFS static archive and keeps parsed Feed in memory.
+// Feed is replaced atomically only when archive content changes,
+// readers can keep using previously returned Feed.
+type Manager struct {
+	client              GTFSGetter
+	interval            time.Duration
+	minDownloadInterval time.Duration
+
+	current atomic.Pointer[snapshot]
+
+	mu           sync.Mutex    // serializes refreshes
+	lastDownload time.Time     // time of the last successful download
+	retryAt      time.Time     // earliest retry after failed download, zero if the last one succeeded
+	retryDelay   time.Duration // backoff of the last failed download
+
+	callbacksMu sync.RWMutex
+	onChange    []func(oldFeed, newFeed *Feed)
+	onError     []func(err error)
+}
+
+// snapshot is the current Feed with SHA-256 of its archive
+type snapshot struct {
+	feed *Feed
+	hash string
+}
+
+// NewManager creates new Manager, interval is how often to check for a new archive,
+// minDownloadInterval caps how often archive is downloaded (DefaultMinDownloadInterval if not positive)
+func NewManager(client GTFSGetter, interval, minDownloadInterval time.Duration) *Manager {
+	if minDownloadInterval <= 0 {
+		minDownloadInterval = DefaultMinDownloadInterval
+	}
+	if interval < minDownloadInterval {
+		interval = minDownloadInterval
+	}
+
+	return &Manager{
+		client:              client,
+		interval:            interval,
+		minDownloadInterval: minDownloadInterval,
+	}
+}
+
+// Feed returns current Feed, nil until the first successful refresh
+func (m *Manager) Feed() *Feed {
+	if current := m.current.Load(); current != nil {
+		return current.feed
+	}
+	return nil
+}
+
+// Version returns feed_version of the current Feed,
+// or SHA-256 of the archive if feed_info.txt has no version
+func (m *Manager) Version() string {
+	current := m.current.Load()
+	if current == nil {
+		return ""
+	}
+	if v := feedVersion(current.feed); v != "" {
+		return v
+	}
+	return current.hash
+}
+
+// OnChange registers a callback called after Feed is replaced,
+// oldFeed is nil on the first load
+func (m *Manager) OnChange(fn func(oldFeed, newFeed *Feed)) {
+	m.callbacksMu.Lock()
+	defer m.callbacksMu.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// OnError registers a callback called when refresh in Run fails
+func (m *Manager) OnError(fn func(err error)) {
+	m.callbacksMu.Lock()
+	defer m.callbacksMu.Unlock()
+	m.onError = append(m.onError, fn)
+}
+
+// Run refreshes Feed every interval until ctx is done, failed downloads
+// are retried with backoff starting at DefaultRetryInterval
+func (m *Manager) Run(ctx context.Context) {
+	scheduled := time.Now()
+	at := scheduled
+	for {
+		if _, err := m.refreshAt(at); err != nil && !errors.Is(err, ErrDownloadTooSoon) {
+			m.callbacksMu.RLock()
+			for _, fn := range m.onError {
+				fn(err)
+			}
+			m.callbacksMu.RUnlock()
+		}
+
+		// refresh is called with scheduled time rather than time.Now, timer jitter
+		// would otherwise make some refreshes ErrDownloadTooSoon and double the period
+		for !scheduled.After(time.Now()) {
+			scheduled = scheduled.Add(m.interval)
+		}
+		at = scheduled
+		m.mu.Lock()
+		if !m.retryAt.IsZero() && m.retryAt.Before(at) {
+			at = m.retryAt
+		}
+		m.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Refresh downloads the archive and replaces Feed if content changed,
+// returns true if Feed was replaced. OnChange callbacks are called
+// after refresh is finished, so they may call Manager methods.
+func (m *Manager) Refresh() (bool, error) {
+	return m.refreshAt(time.Now())
+}
+
+// refreshAt is Refresh with download intervals measured from now
+func (m *Manager) refreshAt(now time.Time) (bool, error) {
+	oldFeed, feed, err := m.refresh(now)
+	if err != nil || feed == nil {
+		return false, err
+	}
+
+	m.callbacksMu.RLock()
+	defer m.callbacksMu.RUnlock()
+	for _, fn := range m.onChange {
+		fn(oldFeed, feed)
+	}
+
+	return true, nil
+}
+
+// refresh replaces Feed if archive content changed and returns previous and new Feed,
+// new Feed is nil if it was not replaced
+func (m *Manager) refresh(now time.Time) (*Feed, *Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.lastDownload.IsZero() && now.Sub(m.lastDownload) < m.minDownloadInterval {
+		return nil, nil, ErrDownloadTooSoon
+	}
+	if now.Before(m.retryAt) {
+		return nil, nil, ErrDownloadTooSoon
+	}
+
+	b, err := m.client.GetGTFS()
+	if err != nil {
+		m.retryDelay = min(max(2*m.retryDelay, DefaultRetryInterval), m.interval)
+		m.retryAt = now.Add(m.retryDelay)
+		return nil, nil, fmt.Errorf("get GTFS: %v", err)
+	}
+	m.lastDownload = now
+	m.retryAt = time.Time{}
+	m.retryDelay = 0
+
+	sum := sha256.Sum256(b)
+	hash := hex.EncodeToString(sum[:])
+
+	var oldFeed *Feed
+	if current := m.current.Load(); current != nil {
+		if current.hash == hash {
+			return nil, nil, nil
+		}
+		oldFeed = current.feed
+	}
+
+	feed, err := Parse(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse GTFS: %v", err)
+	}
+
+	if oldFeed != nil && feedVersion(oldFeed) != "" && feedVersion(oldFeed) == feedVersion(feed) {
+		// archive was rebuilt without content changes
+		m.current.Store(&snapshot{feed: oldFeed, hash: hash})
+		return nil, nil, nil
+	}
+
+	m.current.Store(&snapshot{feed: feed, hash: hash})
+	return oldFeed, feed, nil
+}
+
+func feedVersion(feed *Feed) string {
+	if feed == nil || feed.FeedInfo == nil {
+		return ""
+	}
+	return feed.FeedInfo.Version
+}
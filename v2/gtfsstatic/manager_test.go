@@ -0,0 +1,112 @@
+package gtfsstatic
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type gtfsGetterMock struct {
+	archives [][]byte
+	calls    int
+}
+
+func (g *gtfsGetterMock) GetGTFS() ([]byte, error) {
+	if g.calls >= len(g.archives) {
+		return nil, errors.New("no more archives")
+	}
+	b := g.archives[g.calls]
+	g.calls++
+	return b, nil
+}
+
+func TestManagerRefresh(t *testing.T) {
+	v1 := buildArchive(t, map[string]string{
+		"stops.txt":     "stop_id,stop_name,stop_lat,stop_lon\n1,A,40.7,-74.1\n",
+		"feed_info.txt": "feed_publisher_name,feed_version\nNJ TRANSIT,v1\n",
+	})
+	v1Rebuilt := buildArchive(t, map[string]string{
+		"feed_info.txt": "feed_publisher_name,feed_version\nNJ TRANSIT,v1\n",
+		"stops.txt":     "stop_id,stop_name,stop_lat,stop_lon\n1,A,40.7,-74.1\n",
+	})
+	v2 := buildArchive(t, map[string]string{
+		"stops.txt":     "stop_id,stop_name,stop_lat,stop_lon\n1,A,40.7,-74.1\n2,B,40.8,-74.1\n",
+		"feed_info.txt": "feed_publisher_name,feed_version\nNJ TRANSIT,v2\n",
+	})
+
+	client := &gtfsGetterMock{archives: [][]byte{v1, v1, v1Rebuilt, v2}}
+	m := NewManager(client, time.Nanosecond, time.Nanosecond)
+	assert.Nil(t, m.Feed())
+
+	var changes []string
+	m.OnChange(func(oldFeed, newFeed *Feed) {
+		changes = append(changes, newFeed.FeedInfo.Version)
+	})
+
+	for _, expected := range []bool{true, false, false, true} {
+		time.Sleep(time.Millisecond)
+		changed, err := m.Refresh()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, changed)
+	}
+
+	assert.Equal(t, []string{"v1", "v2"}, changes)
+	assert.Equal(t, "v2", m.Version())
+	assert.Len(t, m.Feed().Stops, 2)
+
+	m = NewManager(client, 0, time.Hour)
+	_, err := m.Refresh()
+	assert.Error(t, err)
+	_, err = m.Refresh()
+	assert.Equal(t, ErrDownloadTooSoon, err)
+
+	// Manager is not locked while callbacks run
+	m = NewManager(&gtfsGetterMock{archives: [][]byte{v1}}, 0, time.Hour)
+	m.OnChange(func(oldFeed, newFeed *Feed) {
+		_, err := m.Refresh()
+		assert.Equal(t, ErrDownloadTooSoon, err)
+	})
+	changed, err := m.Refresh()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+}
+
+// gtfsGetterFunc is a custom GTFSGetter
+type gtfsGetterFunc func() ([]byte, error)
+
+func (f gtfsGetterFunc) GetGTFS() ([]byte, error) {
+	return f()
+}
+
+func TestManagerDownloadSchedule(t *testing.T) {
+	archive := buildArchive(t, map[string]string{
+		"stops.txt": "stop_id,stop_name,stop_lat,stop_lon\n1,A,40.7,-74.1\n",
+	})
+	calls := 0
+	var downloadErr error
+	m := NewManager(gtfsGetterFunc(func() ([]byte, error) {
+		calls++
+		return archive, downloadErr
+	}), time.Hour, time.Hour)
+	start := time.Date(2024, 9, 30, 8, 0, 0, 0, time.UTC)
+
+	// failed download is retried with backoff, not after min download interval
+	downloadErr = errors.New("timeout")
+	_, err := m.refreshAt(start)
+	assert.Error(t, err)
+	_, err = m.refreshAt(start.Add(DefaultRetryInterval / 2))
+	assert.Equal(t, ErrDownloadTooSoon, err)
+	downloadErr = nil
+	changed, err := m.refreshAt(start.Add(DefaultRetryInterval))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	// the next scheduled refresh is exactly min download interval later
+	_, err = m.refreshAt(start.Add(DefaultRetryInterval + time.Hour - time.Second))
+	assert.Equal(t, ErrDownloadTooSoon, err)
+	_, err = m.refreshAt(start.Add(DefaultRetryInterval + time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
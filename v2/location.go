@@ -0,0 +1,24 @@
+package njtransit
+
+import (
+	"fmt"
+	"time"
+
+	// embedded timezone database, so minimal containers without tzdata
+	// don't silently parse NJ TRANSIT local timestamps as UTC
+	_ "time/tzdata"
+)
+
+// newYork is loaded once, LoadLocation reads the timezone database on every call
+var newYork = func() *time.Location {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		panic(fmt.Sprintf("load America/New_York: %v", err))
+	}
+	return location
+}()
+
+// TimeZone returns America/New_York, the timezone of NJ TRANSIT APIs and feeds
+func TimeZone() *time.Location {
+	return newYork
+}
@@ -0,0 +1,248 @@
+package mapmatch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	njtv1 "github.com/errornil/njtransit"
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	gtfs "github.com/errornil/transit_realtime"
+)
+
+// DefaultOffRouteThresholdMeters is the distance from the shape
+// after which vehicle is considered off-route
+const DefaultOffRouteThresholdMeters = 100
+
+// StopOnShape is a trip stop with its position along the shape
+type StopOnShape struct {
+	StopID         string
+	StopSequence   int
+	DistanceMeters float64 // distance from the shape start
+}
+
+// Progress describes vehicle position relative to its trip shape
+type Progress struct {
+	TripID                  string
+	ShapeID                 string
+	DistanceTraveledMeters  float64 // distance along the shape from its start
+	ShapeLengthMeters       float64
+	PercentComplete         float64      // 0..100
+	DistanceFromShapeMeters float64      // distance between vehicle and its projection onto the shape
+	OffRoute                bool         // DistanceFromShapeMeters exceeds threshold
+	PreviousStop            *StopOnShape // last stop passed, nil before the first stop
+	NextStop                *StopOnShape // next stop to serve, nil after the last stop
+}
+
+// Matcher projects vehicle positions onto GTFS shapes, safe for concurrent use
+type Matcher struct {
+	offRouteThreshold float64
+
+	trips     map[string]*gtfsstatic.Trip
+	shapes    map[string]*gtfsstatic.Shape
+	stops     map[string]*gtfsstatic.Stop
+	stopTimes map[string][]gtfsstatic.StopTime
+	routeTrip map[string][]string // route_short_name and route_id to trip IDs with distinct shapes
+
+	mu        sync.Mutex
+	polylines map[string]*polyline     // by shape_id
+	tripStops map[string][]StopOnShape // by trip_id
+}
+
+// NewMatcher creates new Matcher from parsed GTFS static feed,
+// offRouteThreshold is in meters, DefaultOffRouteThresholdMeters is used when it's not positive
+func NewMatcher(feed *gtfsstatic.Feed, offRouteThreshold float64) *Matcher {
+	if offRouteThreshold <= 0 {
+		offRouteThreshold = DefaultOffRouteThresholdMeters
+	}
+
+	m := &Matcher{
+		offRouteThreshold: offRouteThreshold,
+		trips:             feed.TripByID(),
+		shapes:            feed.ShapeByID(),
+		stops:             feed.StopByID(),
+		stopTimes:         feed.StopTimesByTrip(),
+		routeTrip:         map[string][]string{},
+		polylines:         map[string]*polyline{},
+		tripStops:         map[string][]StopOnShape{},
+	}
+
+	routes := feed.RouteByID()
+	seen := map[string]bool{}
+	for _, trip := range feed.Trips {
+		if trip.ShapeID == "" || seen[trip.RouteID+"|"+trip.ShapeID] {
+			continue
+		}
+		seen[trip.RouteID+"|"+trip.ShapeID] = true
+
+		m.routeTrip[trip.RouteID] = append(m.routeTrip[trip.RouteID], trip.ID)
+		if route, ok := routes[trip.RouteID]; ok && route.ShortName != "" && route.ShortName != route.ID {
+			m.routeTrip[route.ShortName] = append(m.routeTrip[route.ShortName], trip.ID)
+		}
+	}
+
+	return m
+}
+
+// Match projects position onto the shape of given trip
+func (m *Matcher) Match(tripID string, lat, lon float64) (*Progress, error) {
+	trip, ok := m.trips[tripID]
+	if !ok {
+		return nil, fmt.Errorf("unknown trip %q", tripID)
+	}
+
+	line, err := m.polyline(trip.ShapeID)
+	if err != nil {
+		return nil, fmt.Errorf("trip %q: %v", tripID, err)
+	}
+
+	p, ok := line.project(lat, lon, 0)
+	if !ok {
+		return nil, fmt.Errorf("trip %q: shape %q is empty", tripID, trip.ShapeID)
+	}
+
+	progress := &Progress{
+		TripID:                  tripID,
+		ShapeID:                 trip.ShapeID,
+		DistanceTraveledMeters:  p.along,
+		ShapeLengthMeters:       line.length(),
+		DistanceFromShapeMeters: p.distance,
+		OffRoute:                p.distance > m.offRouteThreshold,
+	}
+	if progress.ShapeLengthMeters > 0 {
+		progress.PercentComplete = 100 * p.along / progress.ShapeLengthMeters
+	}
+
+	stops := m.stopsOnShape(trip, line)
+	for i := range stops {
+		if stops[i].DistanceMeters <= p.along {
+			progress.PreviousStop = &stops[i]
+			continue
+		}
+		progress.NextStop = &stops[i]
+		break
+	}
+
+	return progress, nil
+}
+
+// MatchVehiclePosition projects GTFS-RT vehicle position (BusClient.GetVehiclePositions)
+// onto its trip shape
+func (m *Matcher) MatchVehiclePosition(vehicle *gtfs.VehiclePosition) (*Progress, error) {
+	if vehicle.GetPosition() == nil {
+		return nil, fmt.Errorf("vehicle has no position")
+	}
+	if vehicle.GetTrip().GetTripId() == "" {
+		return nil, fmt.Errorf("vehicle has no trip")
+	}
+
+	return m.Match(
+		vehicle.GetTrip().GetTripId(),
+		float64(vehicle.GetPosition().GetLatitude()),
+		float64(vehicle.GetPosition().GetLongitude()),
+	)
+}
+
+// MatchBusVehicleDataRow projects legacy BusDataClient vehicle onto a shape.
+// Legacy rows don't carry GTFS trip_id: if tripID is empty, the row is matched
+// against all shapes of its route (by route_short_name or route_id) and the closest one is used.
+func (m *Matcher) MatchBusVehicleDataRow(row njtv1.BusVehicleDataRow, tripID string) (*Progress, error) {
+	lat, err := strconv.ParseFloat(strings.TrimSpace(row.Latitude), 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse latitude %q: %v", row.Latitude, err)
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(row.Longitude), 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse longitude %q: %v", row.Longitude, err)
+	}
+
+	if tripID != "" {
+		return m.Match(tripID, lat, lon)
+	}
+
+	candidates := m.routeTrip[strings.TrimSpace(row.Route)]
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no shapes for route %q", row.Route)
+	}
+
+	var best *Progress
+	for _, id := range candidates {
+		progress, err := m.Match(id, lat, lon)
+		if err != nil {
+			continue
+		}
+		if best == nil || progress.DistanceFromShapeMeters < best.DistanceFromShapeMeters {
+			best = progress
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no shapes for route %q", row.Route)
+	}
+	return best, nil
+}
+
+// TripStops returns stops of given trip with their positions along the trip shape,
+// ordered by stop_sequence
+func (m *Matcher) TripStops(tripID string) ([]StopOnShape, error) {
+	trip, ok := m.trips[tripID]
+	if !ok {
+		return nil, fmt.Errorf("unknown trip %q", tripID)
+	}
+
+	line, err := m.polyline(trip.ShapeID)
+	if err != nil {
+		return nil, fmt.Errorf("trip %q: %v", tripID, err)
+	}
+
+	return m.stopsOnShape(trip, line), nil
+}
+
+func (m *Matcher) polyline(shapeID string) (*polyline, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if line, ok := m.polylines[shapeID]; ok {
+		return line, nil
+	}
+
+	shape, ok := m.shapes[shapeID]
+	if !ok {
+		return nil, fmt.Errorf("unknown shape %q", shapeID)
+	}
+
+	line := newPolyline(shape)
+	m.polylines[shapeID] = line
+	return line, nil
+}
+
+// stopsOnShape projects trip stops onto the shape in stop_sequence order,
+// each stop is searched after the previous one so loops are handled correctly
+func (m *Matcher) stopsOnShape(trip *gtfsstatic.Trip, line *polyline) []StopOnShape {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stops, ok := m.tripStops[trip.ID]; ok {
+		return stops
+	}
+
+	stopTimes := m.stopTimes[trip.ID]
+	stops := make([]StopOnShape, 0, len(stopTimes))
+	segment := 0
+	for _, st := range stopTimes {
+		stop, ok := m.stops[st.StopID]
+		if !ok {
+			continue
+		}
+
+		s := StopOnShape{StopID: st.StopID, StopSequence: st.StopSequence}
+		if p, ok := line.project(stop.Lat, stop.Lon, segment); ok {
+			s.DistanceMeters = p.along
+			segment = p.segment
+		}
+		stops = append(stops, s)
+	}
+
+	m.tripStops[trip.ID] = stops
+	return stops
+}
@@ -0,0 +1,85 @@
+package mapmatch
+
+import (
+	"testing"
+
+	njtv1 "github.com/errornil/njtransit"
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	"github.com/stretchr/testify/assert"
+)
+
+// testFeed has a single trip going east along 40.7 latitude, about 8.4 km long
+func testFeed() *gtfsstatic.Feed {
+	return &gtfsstatic.Feed{
+		Stops: []gtfsstatic.Stop{
+			{ID: "A", Lat: 40.7, Lon: -74.2},
+			{ID: "B", Lat: 40.7001, Lon: -74.15},
+			{ID: "C", Lat: 40.7, Lon: -74.1},
+		},
+		Routes: []gtfsstatic.Route{
+			{ID: "10", ShortName: "1"},
+		},
+		Trips: []gtfsstatic.Trip{
+			{ID: "T1", RouteID: "10", ShapeID: "S1"},
+		},
+		StopTimes: []gtfsstatic.StopTime{
+			{TripID: "T1", StopID: "A", StopSequence: 1},
+			{TripID: "T1", StopID: "B", StopSequence: 2},
+			{TripID: "T1", StopID: "C", StopSequence: 3},
+		},
+		Shapes: []gtfsstatic.Shape{
+			{
+				ID: "S1",
+				Points: []gtfsstatic.ShapePoint{
+					{Lat: 40.7, Lon: -74.2, Sequence: 1},
+					{Lat: 40.7, Lon: -74.15, Sequence: 2},
+					{Lat: 40.7, Lon: -74.1, Sequence: 3},
+				},
+			},
+		},
+	}
+}
+
+func TestMatch(t *testing.T) {
+	m := NewMatcher(testFeed(), 0)
+
+	progress, err := m.Match("T1", 40.7002, -74.175)
+	assert.NoError(t, err)
+	assert.Equal(t, "S1", progress.ShapeID)
+	assert.InDelta(t, 8430, progress.ShapeLengthMeters, 20)
+	assert.InDelta(t, 25, progress.PercentComplete, 0.1)
+	assert.InDelta(t, 22, progress.DistanceFromShapeMeters, 1)
+	assert.False(t, progress.OffRoute)
+	if assert.NotNil(t, progress.PreviousStop) && assert.NotNil(t, progress.NextStop) {
+		assert.Equal(t, "A", progress.PreviousStop.StopID)
+		assert.Equal(t, "B", progress.NextStop.StopID)
+	}
+
+	progress, err = m.Match("T1", 40.71, -74.12)
+	assert.NoError(t, err)
+	assert.True(t, progress.OffRoute)
+	assert.Equal(t, "B", progress.PreviousStop.StopID)
+	assert.Equal(t, "C", progress.NextStop.StopID)
+
+	_, err = m.Match("unknown", 40.7, -74.1)
+	assert.Error(t, err)
+}
+
+func TestMatchBusVehicleDataRow(t *testing.T) {
+	m := NewMatcher(testFeed(), 0)
+
+	progress, err := m.MatchBusVehicleDataRow(njtv1.BusVehicleDataRow{
+		VehicleID: "5987",
+		Route:     "1",
+		Latitude:  "40.7",
+		Longitude: "-74.1",
+	}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "T1", progress.TripID)
+	assert.InDelta(t, 100, progress.PercentComplete, 0.1)
+	assert.Equal(t, "C", progress.PreviousStop.StopID)
+	assert.Nil(t, progress.NextStop)
+
+	_, err = m.MatchBusVehicleDataRow(njtv1.BusVehicleDataRow{Route: "2", Latitude: "40.7", Longitude: "-74.1"}, "")
+	assert.Error(t, err)
+}
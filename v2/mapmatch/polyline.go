@@ -0,0 +1,94 @@
+package mapmatch
+
+import (
+	"math"
+
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	"github.com/errornil/njtransit/v2/spatial"
+)
+
+// polyline is a shape with precomputed cumulative distances
+type polyline struct {
+	lats   []float64
+	lons   []float64
+	cumul  []float64 // distance from the shape start to each point, in meters
+	cosLat float64   // used for local equirectangular projection
+}
+
+// projection is a point projected onto polyline
+type projection struct {
+	segment  int     // index of the segment start point
+	along    float64 // distance along polyline, in meters
+	distance float64 // distance from the point to the polyline, in meters
+}
+
+func newPolyline(shape *gtfsstatic.Shape) *polyline {
+	p := &polyline{
+		lats:  make([]float64, len(shape.Points)),
+		lons:  make([]float64, len(shape.Points)),
+		cumul: make([]float64, len(shape.Points)),
+	}
+
+	var sumLat float64
+	for i, point := range shape.Points {
+		p.lats[i] = point.Lat
+		p.lons[i] = point.Lon
+		sumLat += point.Lat
+		if i > 0 {
+			p.cumul[i] = p.cumul[i-1] + spatial.Distance(p.lats[i-1], p.lons[i-1], point.Lat, point.Lon)
+		}
+	}
+	if len(shape.Points) > 0 {
+		p.cosLat = math.Cos(sumLat / float64(len(shape.Points)) * math.Pi / 180)
+	}
+	return p
+}
+
+// length returns polyline length in meters
+func (p *polyline) length() float64 {
+	if len(p.cumul) == 0 {
+		return 0
+	}
+	return p.cumul[len(p.cumul)-1]
+}
+
+// project finds the closest point on polyline segments starting from fromSegment
+func (p *polyline) project(lat, lon float64, fromSegment int) (projection, bool) {
+	if len(p.lats) == 0 {
+		return projection{}, false
+	}
+	if len(p.lats) == 1 {
+		return projection{distance: spatial.Distance(lat, lon, p.lats[0], p.lons[0])}, true
+	}
+
+	best := projection{distance: math.Inf(1)}
+	px, py := p.xy(lat, lon)
+	for i := fromSegment; i < len(p.lats)-1; i++ {
+		ax, ay := p.xy(p.lats[i], p.lons[i])
+		bx, by := p.xy(p.lats[i+1], p.lons[i+1])
+
+		t := 0.0
+		dx, dy := bx-ax, by-ay
+		if l2 := dx*dx + dy*dy; l2 > 0 {
+			t = math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/l2))
+		}
+
+		// interpolated point back to lat/lon to measure haversine distance
+		lat2 := p.lats[i] + t*(p.lats[i+1]-p.lats[i])
+		lon2 := p.lons[i] + t*(p.lons[i+1]-p.lons[i])
+		d := spatial.Distance(lat, lon, lat2, lon2)
+		if d < best.distance {
+			best = projection{
+				segment:  i,
+				along:    p.cumul[i] + t*(p.cumul[i+1]-p.cumul[i]),
+				distance: d,
+			}
+		}
+	}
+	return best, !math.IsInf(best.distance, 1)
+}
+
+// xy projects lat/lon onto plane, in degrees of latitude
+func (p *polyline) xy(lat, lon float64) (float64, float64) {
+	return lon * p.cosLat, lat
+}
@@ -0,0 +1,283 @@
+// Package metrics collects NJ TRANSIT API usage metrics from clients
+// and streaming pollers and exposes them in Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+)
+
+var (
+	_ njtv1.StreamObserver    = &Collector{}
+	_ njtv1.RoundTripObserver = &Collector{}
+)
+
+// DefaultBuckets are request duration histogram buckets in seconds
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type endpointKey struct {
+	client   string
+	endpoint string
+}
+
+type requestKey struct {
+	endpointKey
+	status string // HTTP status code, "error" if there is no response
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+type stream struct {
+	polls   uint64
+	errors  uint64
+	rows    uint64
+	emitted uint64
+}
+
+// Collector collects metrics, set it with SetRoundTripObserver of every client
+// and use it as http.Handler for /metrics. Collector is safe for concurrent use.
+type Collector struct {
+	buckets  []float64
+	location *time.Location
+	now      func() time.Time
+
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[endpointKey]*histogram
+	quotas    map[endpointKey]int
+	daily     map[endpointKey]int // HTTP requests since local midnight
+	day       string
+	streams   map[string]*stream
+}
+
+// NewCollector creates new Collector with DefaultBuckets,
+// daily quotas are reset at midnight in America/New_York
+func NewCollector() *Collector {
+	return &Collector{
+		buckets:   DefaultBuckets,
+		location:  njt.TimeZone(),
+		now:       time.Now,
+		requests:  map[requestKey]uint64{},
+		durations: map[endpointKey]*histogram{},
+		quotas:    map[endpointKey]int{},
+		daily:     map[endpointKey]int{},
+		streams:   map[string]*stream{},
+	}
+}
+
+// SetDailyQuota sets daily request limit of client endpoint,
+// e.g. ("TrainDataClient", "getStationScheduleXML", 10), to report remaining quota
+func (c *Collector) SetDailyQuota(client, endpoint string, limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.quotas[endpointKey{client: client, endpoint: endpoint}] = limit
+}
+
+// ObserveRoundTrip records volume, latency and errors of HTTP requests sent to API,
+// including logins and retries after token refresh; calls answered from cache are not counted.
+// Implements njtv1.RoundTripObserver, use it with SetRoundTripObserver of
+// BusDataClient, TrainDataClient, BusClient and BusDV2Client.
+func (c *Collector) ObserveRoundTrip(call njtv1.Call, status int, duration time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := endpointKey{client: call.Client, endpoint: call.Endpoint}
+	statusLabel := strconv.Itoa(status)
+	if status == 0 {
+		statusLabel = "error"
+	}
+	c.requests[requestKey{endpointKey: key, status: statusLabel}]++
+
+	h, ok := c.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(c.buckets))}
+		c.durations[key] = h
+	}
+	seconds := duration.Seconds()
+	for i, le := range c.buckets {
+		if seconds <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+
+	c.resetDaily()
+	c.daily[key]++
+}
+
+// ObservePoll records a poll of a streaming poller: rows received, rows emitted after dedupe
+// and poll error, implements njtv1.StreamObserver
+func (c *Collector) ObservePoll(name string, rows, emitted int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.streams[name]
+	if !ok {
+		s = &stream{}
+		c.streams[name] = s
+	}
+	s.polls++
+	if err != nil {
+		s.errors++
+	}
+	s.rows += uint64(rows)
+	s.emitted += uint64(emitted)
+}
+
+// resetDaily clears daily counters after local midnight, must be called with mu held
+func (c *Collector) resetDaily() {
+	day := c.now().In(c.location).Format("2006-01-02")
+	if day != c.day {
+		c.day = day
+		c.daily = map[endpointKey]int{}
+	}
+}
+
+// ServeHTTP writes metrics in Prometheus text exposition format
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+// WriteTo writes metrics in Prometheus text exposition format
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resetDaily()
+
+	bw := &countingWriter{w: bufio.NewWriter(w)}
+
+	requests := make([]requestKey, 0, len(c.requests))
+	for k := range c.requests {
+		requests = append(requests, k)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].endpointKey != requests[j].endpointKey {
+			return requests[i].endpointKey.less(requests[j].endpointKey)
+		}
+		return requests[i].status < requests[j].status
+	})
+	bw.header("njtransit_requests_total", "counter", "HTTP requests sent to NJ TRANSIT API by status, status is \"error\" if there was no response.")
+	for _, k := range requests {
+		bw.printf("njtransit_requests_total{%s,status=%s} %d\n", k.labels(), labelValue(k.status), c.requests[k])
+	}
+
+	bw.header("njtransit_request_duration_seconds", "histogram", "NJ TRANSIT API HTTP request latency.")
+	for _, k := range sortedKeys(c.durations) {
+		h := c.durations[k]
+		var cumulative uint64
+		for i, le := range c.buckets {
+			cumulative += h.counts[i]
+			bw.printf("njtransit_request_duration_seconds_bucket{%s,le=%s} %d\n", k.labels(), labelValue(formatFloat(le)), cumulative)
+		}
+		bw.printf("njtransit_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", k.labels(), h.count)
+		bw.printf("njtransit_request_duration_seconds_sum{%s} %s\n", k.labels(), formatFloat(h.sum))
+		bw.printf("njtransit_request_duration_seconds_count{%s} %d\n", k.labels(), h.count)
+	}
+
+	bw.header("njtransit_quota_remaining", "gauge", "Remaining daily NJ TRANSIT API requests of endpoints with known quota.")
+	for _, k := range sortedKeys(c.quotas) {
+		remaining := c.quotas[k] - c.daily[k]
+		if remaining < 0 {
+			remaining = 0
+		}
+		bw.printf("njtransit_quota_remaining{%s} %d\n", k.labels(), remaining)
+	}
+
+	names := make([]string, 0, len(c.streams))
+	for name := range c.streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	bw.header("njtransit_stream_polls_total", "counter", "Polls of streaming pollers.")
+	for _, name := range names {
+		bw.printf("njtransit_stream_polls_total{stream=%s} %d\n", labelValue(name), c.streams[name].polls)
+	}
+	bw.header("njtransit_stream_poll_errors_total", "counter", "Failed polls of streaming pollers.")
+	for _, name := range names {
+		bw.printf("njtransit_stream_poll_errors_total{stream=%s} %d\n", labelValue(name), c.streams[name].errors)
+	}
+	bw.header("njtransit_stream_rows_total", "counter", "Rows received by streaming pollers.")
+	for _, name := range names {
+		bw.printf("njtransit_stream_rows_total{stream=%s} %d\n", labelValue(name), c.streams[name].rows)
+	}
+	bw.header("njtransit_stream_emitted_total", "counter", "Rows emitted by streaming pollers after dedupe.")
+	for _, name := range names {
+		bw.printf("njtransit_stream_emitted_total{stream=%s} %d\n", labelValue(name), c.streams[name].emitted)
+	}
+
+	if bw.err != nil {
+		return bw.n, bw.err
+	}
+	return bw.n, bw.w.Flush()
+}
+
+func (k endpointKey) labels() string {
+	return fmt.Sprintf("client=%s,endpoint=%s", labelValue(k.client), labelValue(k.endpoint))
+}
+
+// labelEscaper escapes label values as Prometheus text format does,
+// unlike %q it keeps non-ASCII characters as is
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelValue returns quoted and escaped label value
+func labelValue(s string) string {
+	return `"` + labelEscaper.Replace(s) + `"`
+}
+
+func (k endpointKey) less(other endpointKey) bool {
+	if k.client != other.client {
+		return k.client < other.client
+	}
+	return k.endpoint < other.endpoint
+}
+
+func sortedKeys[V any](m map[endpointKey]V) []endpointKey {
+	keys := make([]endpointKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+	return keys
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// countingWriter keeps the first write error and number of bytes written
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...any) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func (cw *countingWriter) header(name, typ, help string) {
+	cw.printf("# HELP %s %s\n# TYPE %s %s\n", name, strings.TrimSpace(help), name, typ)
+}
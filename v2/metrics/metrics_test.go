@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePCSData issues token-N on N-th login and accepts only the latest token
+type fakePCSData struct {
+	logins int
+}
+
+func (f *fakePCSData) Do(req *http.Request) (*http.Response, error) {
+	if path.Base(req.URL.Path) == "authenticateUser" {
+		f.logins++
+		return f.response(http.StatusOK, fmt.Sprintf(`{"Authenticated":"True","UserToken":"token-%d"}`, f.logins)), nil
+	}
+
+	err := req.ParseMultipartForm(1 << 20)
+	if err != nil {
+		return nil, err
+	}
+	if req.MultipartForm.Value["token"][0] != fmt.Sprintf("token-%d", f.logins) {
+		return f.response(http.StatusUnauthorized, ""), nil
+	}
+	return f.response(http.StatusOK, `[]`), nil
+}
+
+func (f *fakePCSData) response(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewBufferString(body))}
+}
+
+func metricsLines(c *Collector) []string {
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return strings.Split(rec.Body.String(), "\n")
+}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	now := time.Date(2024, 10, 1, 23, 0, 0, 0, c.location)
+	c.now = func() time.Time { return now }
+	c.SetDailyQuota("TrainDataClient", "getStationScheduleXML", 10)
+
+	for i := 0; i < 3; i++ {
+		c.ObserveRoundTrip(njtv1.Call{Client: "TrainDataClient", Endpoint: "getStationScheduleXML"}, http.StatusOK, time.Second, nil)
+	}
+	c.ObserveRoundTrip(njtv1.Call{Client: "TrainDataClient", Endpoint: "getStationMSGXML"}, 0, time.Second, errors.New("timeout"))
+	c.ObservePoll("GetBusVehicleDataStream", 10, 4, nil)
+	c.ObservePoll("GetBusVehicleDataStream", 0, 0, errors.New("timeout"))
+
+	lines := metricsLines(c)
+	for _, line := range []string{
+		"# TYPE njtransit_requests_total counter",
+		`njtransit_requests_total{client="TrainDataClient",endpoint="getStationMSGXML",status="error"} 1`,
+		`njtransit_requests_total{client="TrainDataClient",endpoint="getStationScheduleXML",status="200"} 3`,
+		"# TYPE njtransit_request_duration_seconds histogram",
+		`njtransit_request_duration_seconds_bucket{client="TrainDataClient",endpoint="getStationScheduleXML",le="+Inf"} 3`,
+		`njtransit_request_duration_seconds_count{client="TrainDataClient",endpoint="getStationScheduleXML"} 3`,
+		`njtransit_quota_remaining{client="TrainDataClient",endpoint="getStationScheduleXML"} 7`,
+		`njtransit_stream_polls_total{stream="GetBusVehicleDataStream"} 2`,
+		`njtransit_stream_poll_errors_total{stream="GetBusVehicleDataStream"} 1`,
+		`njtransit_stream_rows_total{stream="GetBusVehicleDataStream"} 10`,
+		`njtransit_stream_emitted_total{stream="GetBusVehicleDataStream"} 4`,
+	} {
+		assert.Contains(t, lines, line)
+	}
+
+	// quota is reset after local midnight
+	now = now.Add(2 * time.Hour)
+	assert.Contains(t, metricsLines(c), `njtransit_quota_remaining{client="TrainDataClient",endpoint="getStationScheduleXML"} 10`)
+}
+
+func TestCollectorRoundTrips(t *testing.T) {
+	c := NewCollector()
+	c.SetDailyQuota("BusDV2Client", "getRouteTrips", 10)
+
+	fake := &fakePCSData{}
+	session := njt.NewSession(njt.BusDVTestURL, njt.StaticCredentials{Username: "user", Password: "pass"}, "test", fake)
+	busDV := njt.NewBusDV2ClientWithSession(njt.BusDVTestURL, session)
+	busDV.SetRoundTripObserver(c)
+
+	// calls answered by middleware without sending a request are not counted, login is
+	cached := true
+	busDV.Use(func(next njt.Handler) njt.Handler {
+		return func(call njt.Call) (*njt.Response, error) {
+			if cached && call.Endpoint != "authenticateUser" {
+				return &njt.Response{StatusCode: http.StatusOK, Body: []byte(`[]`)}, nil
+			}
+			return next(call)
+		}
+	})
+	_, err := busDV.GetRouteTrips("PABT", "1")
+	assert.NoError(t, err)
+	cached = false
+
+	// request with token of the first login
+	_, err = busDV.GetRouteTrips("PABT", "1")
+	assert.NoError(t, err)
+
+	// token expires: rejected request, login, retry
+	fake.logins++
+	_, err = busDV.GetRouteTrips("PABT", "1")
+	assert.NoError(t, err)
+
+	lines := metricsLines(c)
+	for _, line := range []string{
+		`njtransit_requests_total{client="BusDV2Client",endpoint="authenticateUser",status="200"} 2`,
+		`njtransit_requests_total{client="BusDV2Client",endpoint="getRouteTrips",status="200"} 2`,
+		`njtransit_requests_total{client="BusDV2Client",endpoint="getRouteTrips",status="401"} 1`,
+		`njtransit_request_duration_seconds_count{client="BusDV2Client",endpoint="getRouteTrips"} 3`,
+		`njtransit_quota_remaining{client="BusDV2Client",endpoint="getRouteTrips"} 7`,
+	} {
+		assert.Contains(t, lines, line)
+	}
+}
+
+func TestCollectorLabelEscaping(t *testing.T) {
+	c := NewCollector()
+	c.ObservePoll("Gare du Nord → \"PABT\"\\\n", 1, 1, nil)
+
+	assert.Contains(t, metricsLines(c), `njtransit_stream_polls_total{stream="Gare du Nord → \"PABT\"\\\n"} 1`)
+}
@@ -0,0 +1,74 @@
+package njtransit
+
+import (
+	"log/slog"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+)
+
+// Middleware types are shared with legacy BusDataClient and TrainDataClient,
+// so the same Middleware can be used with every client, see njtv1.Middleware
+type (
+	Call       = njtv1.Call
+	Response   = njtv1.Response
+	Handler    = njtv1.Handler
+	Middleware = njtv1.Middleware
+	Hooks      = njtv1.Hooks
+
+	RoundTripObserver = njtv1.RoundTripObserver
+)
+
+// instrumentation holds logger, middlewares and observer of a client
+type instrumentation struct {
+	client      string // client name for Call.Client
+	logger      *slog.Logger
+	middlewares []Middleware
+	observer    RoundTripObserver
+}
+
+// SetLogger sets logger for request events at debug level, nil disables logging.
+// Login events are logged by Session, see Session.SetLogger.
+func (in *instrumentation) SetLogger(logger *slog.Logger) {
+	in.logger = logger
+}
+
+// Use adds middlewares wrapping every HTTP request to API, including logins
+// and retries after token refresh; the first added is the outermost
+func (in *instrumentation) Use(middlewares ...Middleware) {
+	in.middlewares = append(in.middlewares, middlewares...)
+}
+
+// SetRoundTripObserver sets observer of HTTP requests sent to API, nil disables it
+func (in *instrumentation) SetRoundTripObserver(observer RoundTripObserver) {
+	in.observer = observer
+}
+
+func (in *instrumentation) name() string {
+	if in == nil {
+		return ""
+	}
+	return in.client
+}
+
+// roundTrip sends a single HTTP request with send through middlewares and observer,
+// in may be nil for Session calls made outside of clients
+func (in *instrumentation) roundTrip(call Call, send func() (*Response, error)) (*Response, error) {
+	if in == nil {
+		return send()
+	}
+
+	observed := func(call Call) (*Response, error) {
+		start := time.Now()
+		resp, err := send()
+		if in.observer != nil {
+			status := 0
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			in.observer.ObserveRoundTrip(call, status, time.Since(start), err)
+		}
+		return resp, err
+	}
+	return njtv1.Chain(in.middlewares, observed)(call)
+}
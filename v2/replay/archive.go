@@ -0,0 +1,115 @@
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Archive files are stored as <dir>/<kind>/<YYYYMMDD>/<unix nanoseconds>.<ext>.gz
+const (
+	extProto = "pb"
+	extJSON  = "json"
+)
+
+func snapshotPath(dir, kind, ext string, t time.Time) string {
+	return filepath.Join(
+		dir,
+		kind,
+		t.UTC().Format("20060102"),
+		fmt.Sprintf("%d.%s.gz", t.UnixNano(), ext),
+	)
+}
+
+func writeSnapshot(dir, kind, ext string, t time.Time, b []byte) error {
+	path := snapshotPath(dir, kind, ext, t)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create directory: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(b); err != nil {
+		return fmt.Errorf("compress: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compress: %v", err)
+	}
+
+	// listSnapshots only picks up *.gz names, so a Replayer reading the archive
+	// while Recorder is running never opens a half-written .gz.tmp file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("write: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename: %v", err)
+	}
+	return nil
+}
+
+func readSnapshot(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("decompress %s: %v", path, err)
+	}
+	defer zr.Close()
+
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompress %s: %v", path, err)
+	}
+	return b, nil
+}
+
+// snapshot is an archived response
+type snapshot struct {
+	time time.Time
+	path string
+}
+
+// listSnapshots returns snapshots of given kind ordered by time
+func listSnapshots(dir, kind string) ([]snapshot, error) {
+	var snapshots []snapshot
+	root := filepath.Join(dir, kind)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".gz") {
+			return nil
+		}
+
+		nanos, err := strconv.ParseInt(strings.SplitN(d.Name(), ".", 2)[0], 10, 64)
+		if err != nil {
+			return nil
+		}
+		snapshots = append(snapshots, snapshot{time: time.Unix(0, nanos), path: path})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %v", root, err)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].time.Before(snapshots[j].time)
+	})
+	return snapshots, nil
+}
@@ -0,0 +1,120 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+	gtfs "github.com/errornil/transit_realtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// Recorder wraps clients and persists each successful response
+// to a compressed, timestamp-indexed archive in dir.
+// Recorder implements the same interfaces as wrapped clients,
+// clients that are not needed can be nil.
+type Recorder struct {
+	dir              string
+	bus              BusFeeds
+	busVehicleData   BusVehicleData
+	vehicleLocations VehicleLocations
+	now              func() time.Time
+}
+
+// NewRecorder creates new Recorder
+func NewRecorder(dir string, bus BusFeeds, busVehicleData BusVehicleData, vehicleLocations VehicleLocations) *Recorder {
+	return &Recorder{
+		dir:              dir,
+		bus:              bus,
+		busVehicleData:   busVehicleData,
+		vehicleLocations: vehicleLocations,
+		now:              time.Now,
+	}
+}
+
+// GetTripUpdates calls BusClient.GetTripUpdates and records the response
+func (r *Recorder) GetTripUpdates() (*gtfs.FeedMessage, error) {
+	if r.bus == nil {
+		return nil, fmt.Errorf("bus client is not set")
+	}
+	return r.recordFeed(KindTripUpdates, r.bus.GetTripUpdates)
+}
+
+// GetVehiclePositions calls BusClient.GetVehiclePositions and records the response
+func (r *Recorder) GetVehiclePositions() (*gtfs.FeedMessage, error) {
+	if r.bus == nil {
+		return nil, fmt.Errorf("bus client is not set")
+	}
+	return r.recordFeed(KindVehiclePositions, r.bus.GetVehiclePositions)
+}
+
+// GetAlerts calls BusClient.GetAlerts and records the response
+func (r *Recorder) GetAlerts() (*gtfs.FeedMessage, error) {
+	if r.bus == nil {
+		return nil, fmt.Errorf("bus client is not set")
+	}
+	return r.recordFeed(KindAlerts, r.bus.GetAlerts)
+}
+
+// GetBusVehicleData calls BusDataClient.GetBusVehicleData and records the response
+func (r *Recorder) GetBusVehicleData() (*njtv1.GetBusVehicleDataResponse, error) {
+	if r.busVehicleData == nil {
+		return nil, fmt.Errorf("bus data client is not set")
+	}
+
+	resp, err := r.busVehicleData.GetBusVehicleData()
+	if err != nil {
+		return nil, err
+	}
+	if err = r.recordJSON(KindBusVehicleData, resp); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// GetVehicleLocationsWithRequest calls BusDV2Client.GetVehicleLocationsWithRequest and records the response
+func (r *Recorder) GetVehicleLocationsWithRequest(request njt.GetVehicleLocationsRequest) (*njt.GetVehicleLocations, error) {
+	if r.vehicleLocations == nil {
+		return nil, fmt.Errorf("BUSDV2 client is not set")
+	}
+
+	resp, err := r.vehicleLocations.GetVehicleLocationsWithRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.recordJSON(KindVehicleLocations, resp); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// recordFeed calls fetch and records its response,
+// response is returned together with recording error, if any
+func (r *Recorder) recordFeed(kind string, fetch func() (*gtfs.FeedMessage, error)) (*gtfs.FeedMessage, error) {
+	feed, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := proto.Marshal(feed)
+	if err != nil {
+		return feed, fmt.Errorf("record %s: marshal: %v", kind, err)
+	}
+	if err = writeSnapshot(r.dir, kind, extProto, r.now(), b); err != nil {
+		return feed, fmt.Errorf("record %s: %v", kind, err)
+	}
+	return feed, nil
+}
+
+func (r *Recorder) recordJSON(kind string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("record %s: marshal: %v", kind, err)
+	}
+	if err = writeSnapshot(r.dir, kind, extJSON, r.now(), b); err != nil {
+		return fmt.Errorf("record %s: %v", kind, err)
+	}
+	return nil
+}
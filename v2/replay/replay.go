@@ -0,0 +1,39 @@
+package replay
+
+import (
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+	gtfs "github.com/errornil/transit_realtime"
+)
+
+// Kinds of recorded responses, used as archive subdirectories
+const (
+	KindTripUpdates      = "tripupdates"
+	KindVehiclePositions = "vehiclepositions"
+	KindAlerts           = "alerts"
+	KindBusVehicleData   = "busvehicledata"
+	KindVehicleLocations = "vehiclelocations"
+)
+
+// BusFeeds is implemented by BusClient, Recorder and Replayer
+type BusFeeds interface {
+	GetTripUpdates() (*gtfs.FeedMessage, error)
+	GetVehiclePositions() (*gtfs.FeedMessage, error)
+	GetAlerts() (*gtfs.FeedMessage, error)
+}
+
+// BusVehicleData is implemented by BusDataClient, Recorder and Replayer
+type BusVehicleData interface {
+	GetBusVehicleData() (*njtv1.GetBusVehicleDataResponse, error)
+}
+
+// VehicleLocations is implemented by BusDV2Client, Recorder and Replayer
+type VehicleLocations interface {
+	GetVehicleLocationsWithRequest(request njt.GetVehicleLocationsRequest) (*njt.GetVehicleLocations, error)
+}
+
+var (
+	_ BusFeeds         = &njt.BusClient{}
+	_ BusVehicleData   = &njtv1.BusDataClient{}
+	_ VehicleLocations = &njt.BusDV2Client{}
+)
@@ -0,0 +1,92 @@
+package replay
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+	"github.com/errornil/njtransit/v2/gtfsrt"
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+type busFeedsMock struct {
+	vehicles string
+}
+
+func (b *busFeedsMock) GetTripUpdates() (*gtfs.FeedMessage, error) {
+	return gtfsrt.NewFeedMessage(nil, time.Unix(1727700000, 0)), nil
+}
+
+func (b *busFeedsMock) GetVehiclePositions() (*gtfs.FeedMessage, error) {
+	return gtfsrt.NewFeedMessage([]*gtfs.FeedEntity{
+		{Id: proto.String(b.vehicles), Vehicle: &gtfs.VehiclePosition{}},
+	}, time.Unix(1727700000, 0)), nil
+}
+
+func (b *busFeedsMock) GetAlerts() (*gtfs.FeedMessage, error) {
+	return gtfsrt.NewFeedMessage(nil, time.Unix(1727700000, 0)), nil
+}
+
+type busVehicleDataMock struct{}
+
+func (b *busVehicleDataMock) GetBusVehicleData() (*njtv1.GetBusVehicleDataResponse, error) {
+	return &njtv1.GetBusVehicleDataResponse{
+		Rows: []njtv1.BusVehicleDataRow{{VehicleID: "5987", Route: "1"}},
+	}, nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 9, 30, 8, 0, 0, 0, time.UTC)
+
+	bus := &busFeedsMock{}
+	recorder := NewRecorder(dir, bus, &busVehicleDataMock{}, nil)
+	for i, vehicle := range []string{"first", "second", "third"} {
+		recorder.now = func() time.Time { return start.Add(time.Duration(i) * time.Minute) }
+		bus.vehicles = vehicle
+		_, err := recorder.GetVehiclePositions()
+		assert.NoError(t, err)
+	}
+	_, err := recorder.GetBusVehicleData()
+	assert.NoError(t, err)
+	_, err = recorder.GetVehicleLocationsWithRequest(njt.GetVehicleLocationsRequest{Lat: 40.7, Lon: -74.1, Radius: 2000 * njt.Foot, Mode: njt.ModeAll})
+	assert.Error(t, err)
+
+	replayer, err := NewReplayer(dir, 60)
+	assert.NoError(t, err)
+	recordingStart, recordingEnd := replayer.Recording()
+	assert.Equal(t, start, recordingStart.UTC())
+	assert.Equal(t, start.Add(2*time.Minute), recordingEnd.UTC())
+
+	// one recorded minute per real second
+	clock := time.Now()
+	replayer.now = func() time.Time { return clock }
+	replayer.Seek(start)
+
+	for _, step := range []struct {
+		elapsed  time.Duration
+		expected string
+	}{
+		{0, "first"},
+		{500 * time.Millisecond, "first"},
+		{time.Second, "second"},
+		{10 * time.Second, "third"},
+	} {
+		clock = clock.Add(step.elapsed)
+		feed, err := replayer.GetVehiclePositions()
+		assert.NoError(t, err)
+		assert.Equal(t, step.expected, feed.GetEntity()[0].GetId())
+	}
+	assert.True(t, replayer.Done())
+
+	rows, err := replayer.GetBusVehicleData()
+	assert.NoError(t, err)
+	assert.Equal(t, "5987", rows.Rows[0].VehicleID)
+
+	_, err = replayer.GetAlerts()
+	assert.True(t, errors.Is(err, ErrNoSnapshots))
+}
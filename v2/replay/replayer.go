@@ -0,0 +1,200 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+	gtfs "github.com/errornil/transit_realtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNoSnapshots is returned by Replayer when archive has no snapshots of requested kind
+var ErrNoSnapshots = errors.New("no recorded snapshots")
+
+// Replayer serves recorded snapshots through the same interfaces as clients.
+// It keeps a virtual clock which starts at the first recorded snapshot and runs
+// speed times faster than real time; each call returns the latest snapshot
+// recorded at or before the virtual clock. Replayer is safe for concurrent use.
+type Replayer struct {
+	snapshots map[string][]snapshot
+	now       func() time.Time
+
+	mu        sync.Mutex
+	speed     float64
+	origin    time.Time // virtual time at started
+	started   time.Time // real time when origin was set
+	recording [2]time.Time
+}
+
+// NewReplayer loads archive index from dir created by Recorder,
+// speed is the replay acceleration (1 - real time, 60 - one recorded minute per second)
+func NewReplayer(dir string, speed float64) (*Replayer, error) {
+	if speed <= 0 {
+		speed = 1
+	}
+
+	r := &Replayer{
+		snapshots: map[string][]snapshot{},
+		now:       time.Now,
+		speed:     speed,
+	}
+
+	kinds := []string{KindTripUpdates, KindVehiclePositions, KindAlerts, KindBusVehicleData, KindVehicleLocations}
+	for _, kind := range kinds {
+		snapshots, err := listSnapshots(dir, kind)
+		if err != nil {
+			return nil, err
+		}
+		if len(snapshots) == 0 {
+			continue
+		}
+		r.snapshots[kind] = snapshots
+
+		if first := snapshots[0].time; r.recording[0].IsZero() || first.Before(r.recording[0]) {
+			r.recording[0] = first
+		}
+		if last := snapshots[len(snapshots)-1].time; last.After(r.recording[1]) {
+			r.recording[1] = last
+		}
+	}
+
+	r.origin = r.recording[0]
+	r.started = r.now()
+	return r, nil
+}
+
+// Recording returns time range of recorded snapshots
+func (r *Replayer) Recording() (start, end time.Time) {
+	return r.recording[0], r.recording[1]
+}
+
+// Seek moves virtual clock to t
+func (r *Replayer) Seek(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.origin = t
+	r.started = r.now()
+}
+
+// SetSpeed changes replay acceleration, keeping the current virtual time
+func (r *Replayer) SetSpeed(speed float64) {
+	if speed <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.origin = r.virtualNow()
+	r.started = r.now()
+	r.speed = speed
+}
+
+// Now returns the current virtual time
+func (r *Replayer) Now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.virtualNow()
+}
+
+// Done returns true when virtual clock passed the last recorded snapshot
+func (r *Replayer) Done() bool {
+	return r.Now().After(r.recording[1])
+}
+
+// GetTripUpdates returns recorded BusClient.GetTripUpdates response
+func (r *Replayer) GetTripUpdates() (*gtfs.FeedMessage, error) {
+	return r.feed(KindTripUpdates)
+}
+
+// GetVehiclePositions returns recorded BusClient.GetVehiclePositions response
+func (r *Replayer) GetVehiclePositions() (*gtfs.FeedMessage, error) {
+	return r.feed(KindVehiclePositions)
+}
+
+// GetAlerts returns recorded BusClient.GetAlerts response
+func (r *Replayer) GetAlerts() (*gtfs.FeedMessage, error) {
+	return r.feed(KindAlerts)
+}
+
+// GetBusVehicleData returns recorded BusDataClient.GetBusVehicleData response
+func (r *Replayer) GetBusVehicleData() (*njtv1.GetBusVehicleDataResponse, error) {
+	response := &njtv1.GetBusVehicleDataResponse{}
+	if err := r.json(KindBusVehicleData, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// GetVehicleLocationsWithRequest returns recorded BusDV2Client.GetVehicleLocationsWithRequest response,
+// request is ignored: recorded response is returned as is
+func (r *Replayer) GetVehicleLocationsWithRequest(request njt.GetVehicleLocationsRequest) (*njt.GetVehicleLocations, error) {
+	response := &njt.GetVehicleLocations{}
+	if err := r.json(KindVehicleLocations, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (r *Replayer) feed(kind string) (*gtfs.FeedMessage, error) {
+	b, err := r.current(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &gtfs.FeedMessage{}
+	if err = proto.Unmarshal(b, feed); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %v", err)
+	}
+	return feed, nil
+}
+
+func (r *Replayer) json(kind string, v interface{}) error {
+	b, err := r.current(kind)
+	if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("unmarshal response: %v", err)
+	}
+	return nil
+}
+
+// current reads the latest snapshot at or before virtual time,
+// or the first one if virtual time is before the recording
+func (r *Replayer) current(kind string) ([]byte, error) {
+	snapshots := r.snapshots[kind]
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("%s: %w", kind, ErrNoSnapshots)
+	}
+
+	now := r.Now()
+	i := sort.Search(len(snapshots), func(i int) bool {
+		return snapshots[i].time.After(now)
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	return readSnapshot(snapshots[i].path)
+}
+
+func (r *Replayer) virtualNow() time.Time {
+	elapsed := r.now().Sub(r.started)
+	return r.origin.Add(time.Duration(float64(elapsed) * r.speed))
+}
+
+var (
+	_ BusFeeds         = &Replayer{}
+	_ BusVehicleData   = &Replayer{}
+	_ VehicleLocations = &Replayer{}
+	_ BusFeeds         = &Recorder{}
+	_ BusVehicleData   = &Recorder{}
+	_ VehicleLocations = &Recorder{}
+)
@@ -0,0 +1,349 @@
+package njtransit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	njtv1 "github.com/errornil/njtransit"
+)
+
+// Credentials of pcsdata.njtransit.com user
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CredentialsProvider supplies credentials on each login,
+// so rotated passwords are picked up on the next token refresh
+type CredentialsProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// CredentialsProviderFunc is a custom CredentialsProvider
+type CredentialsProviderFunc func() (Credentials, error)
+
+func (f CredentialsProviderFunc) Credentials() (Credentials, error) {
+	return f()
+}
+
+// StaticCredentials always returns the same credentials
+type StaticCredentials Credentials
+
+func (c StaticCredentials) Credentials() (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// EnvCredentials reads credentials from environment variables, e.g. BUS_USERNAME and BUS_PASSWORD
+func EnvCredentials(usernameVar, passwordVar string) CredentialsProvider {
+	return CredentialsProviderFunc(func() (Credentials, error) {
+		c := Credentials{Username: os.Getenv(usernameVar), Password: os.Getenv(passwordVar)}
+		if c.Username == "" || c.Password == "" {
+			return Credentials{}, fmt.Errorf("%s and %s must be set", usernameVar, passwordVar)
+		}
+		return c, nil
+	})
+}
+
+// FileCredentials reads credentials from JSON file {"username": "...", "password": "..."}
+func FileCredentials(path string) CredentialsProvider {
+	return CredentialsProviderFunc(func() (Credentials, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("read credentials: %v", err)
+		}
+		c := Credentials{}
+		if err = json.Unmarshal(b, &c); err != nil {
+			return Credentials{}, fmt.Errorf("unmarshal credentials: %v", err)
+		}
+		return c, nil
+	})
+}
+
+// Session holds pcsdata.njtransit.com token shared by BusClient, BusDV2Client
+// and other v2 clients. Token is obtained on first use and refreshed once
+// for all clients when API rejects it. Session is safe for concurrent use.
+type Session struct {
+	url         string
+	credentials CredentialsProvider
+	userAgent   string
+	client      HTTPClient
+	logger      *slog.Logger
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewSession creates new Session, url is any pcsdata API URL, e.g. BusProdURL or BusDVProdURL
+func NewSession(url string, credentials CredentialsProvider, userAgent string, client HTTPClient) *Session {
+	return &Session{
+		url:         url,
+		credentials: credentials,
+		userAgent:   userAgent,
+		client:      client,
+	}
+}
+
+// SetLogger sets logger for login events at debug level, nil disables logging
+func (s *Session) SetLogger(logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logger = logger
+}
+
+// Token returns current token, logging in if there is none yet
+func (s *Session) Token() (string, error) {
+	return s.tokenFor(nil)
+}
+
+// Refresh logs in again unless the token was already refreshed by another caller
+// after stale token was rejected, and returns the new token
+func (s *Session) Refresh(stale string) (string, error) {
+	return s.refreshFor(nil, stale)
+}
+
+// Login gets new token regardless of the current one
+func (s *Session) Login() error {
+	return s.loginFor(nil)
+}
+
+// tokenFor is Token with login sent through middlewares and observer of in,
+// in is instrumentation of the client that needs the token, nil if none
+func (s *Session) tokenFor(in *instrumentation) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" {
+		return s.token, nil
+	}
+	return s.authenticate(in)
+}
+
+// refreshFor is Refresh with login sent through middlewares and observer of in
+func (s *Session) refreshFor(in *instrumentation, stale string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && s.token != stale {
+		return s.token, nil
+	}
+	return s.authenticate(in)
+}
+
+// loginFor is Login with login sent through middlewares and observer of in
+func (s *Session) loginFor(in *instrumentation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.authenticate(in)
+	return err
+}
+
+// authenticate gets new token, must be called with mu held
+func (s *Session) authenticate(in *instrumentation) (string, error) {
+	start := time.Now()
+	status := 0
+	resp, err := in.roundTrip(Call{Client: in.name(), Endpoint: "authenticateUser"}, s.sendLogin)
+	token := ""
+	if err == nil {
+		status = resp.StatusCode
+		token, err = parseLogin(resp)
+	}
+
+	if s.logger != nil {
+		attrs := []any{"endpoint", "authenticateUser", "status", status, "latency", time.Since(start)}
+		if err != nil {
+			attrs = append(attrs, "error", err)
+		}
+		s.logger.Debug("njtransit login", attrs...)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token
+	return token, nil
+}
+
+func (s *Session) sendLogin() (*Response, error) {
+	credentials, err := s.credentials.Credentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials: %v", err)
+	}
+
+	// set username and password as data-url-encoded
+	body := neturl.Values{}
+	body.Add("username", credentials.Username)
+	body.Add("password", credentials.Password)
+	b := body.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, s.url+"authenticateUser", strings.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	req.Header.Set("User-Agent", s.userAgent)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	respb := bytes.Buffer{}
+	_, err = io.Copy(&respb, resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: respb.Bytes()}, nil
+}
+
+func parseLogin(resp *Response) (string, error) {
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to authenticate user, status code: %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Authenticated string `json:"Authenticated"`
+		UserToken     string `json:"UserToken"`
+	}
+
+	err := json.Unmarshal(resp.Body, &response)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode response: %v, body: %s", err, resp.Body)
+	}
+
+	if response.Authenticated != "True" {
+		return "", fmt.Errorf("failed to authenticate user")
+	}
+
+	return response.UserToken, nil
+}
+
+// post calls API endpoint with token and bodyPairs as multipart form,
+// token is refreshed and the call is retried once if API rejects it.
+// Each HTTP request, including login and retry, goes through middlewares and observer of in.
+// The call is logged to in.logger at debug level if logger is set.
+func (s *Session) post(in *instrumentation, url string, bodyPairs []string) ([]byte, error) {
+	if len(bodyPairs)%2 != 0 {
+		return nil, fmt.Errorf("bodyPairs must be even")
+	}
+
+	params := neturl.Values{}
+	for i := 0; i < len(bodyPairs); i += 2 {
+		params.Add(bodyPairs[i], bodyPairs[i+1])
+	}
+
+	start := time.Now()
+	resp, retries, err := s.postWithRetry(in, url, params, bodyPairs)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+
+	if in.logger != nil {
+		status, size := 0, 0
+		if resp != nil {
+			status, size = resp.StatusCode, len(resp.Body)
+		}
+		attrs := []any{
+			"endpoint", path.Base(url),
+			"status", status,
+			"latency", time.Since(start),
+			"bytes", size,
+			"retries", retries,
+		}
+		if err != nil {
+			attrs = append(attrs, "error", err)
+		}
+		in.logger.Debug("njtransit request", attrs...)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *Session) postWithRetry(in *instrumentation, url string, params neturl.Values, bodyPairs []string) (*Response, int, error) {
+	token, err := s.tokenFor(in)
+	if err != nil {
+		return nil, 0, fmt.Errorf("authenticate: %v", err)
+	}
+
+	call := njtv1.NewCall(in.name(), path.Base(url), params)
+	resp, err := in.roundTrip(call, func() (*Response, error) {
+		return s.postWithToken(url, token, bodyPairs)
+	})
+	if err != nil || (resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden) {
+		return resp, 0, err
+	}
+
+	token, err = s.refreshFor(in, token)
+	if err != nil {
+		return resp, 0, fmt.Errorf("refresh token: %v", err)
+	}
+
+	call.Retries = 1
+	resp, err = in.roundTrip(call, func() (*Response, error) {
+		return s.postWithToken(url, token, bodyPairs)
+	})
+	return resp, 1, err
+}
+
+func (s *Session) postWithToken(url, token string, bodyPairs []string) (*Response, error) {
+	reqBody := &bytes.Buffer{}
+	writer := multipart.NewWriter(reqBody)
+	err := writer.WriteField("token", token)
+	if err != nil {
+		return nil, fmt.Errorf("write: %v", err)
+	}
+	for i := 0; i < len(bodyPairs); i += 2 {
+		err = writer.WriteField(bodyPairs[i], bodyPairs[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("write: %v", err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, fmt.Errorf("close writer: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+writer.Boundary())
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set("Accept", "*/*")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("call API: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	body := bytes.Buffer{}
+	_, err = body.ReadFrom(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %v", err)
+	}
+
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body.Bytes()}, nil
+}
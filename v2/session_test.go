@@ -0,0 +1,161 @@
+package njtransit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePCSData issues token-N on N-th login and accepts only the latest token
+type fakePCSData struct {
+	mu     sync.Mutex
+	logins int
+}
+
+func (f *fakePCSData) Do(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if path.Base(req.URL.Path) == "authenticateUser" {
+		f.logins++
+		return f.response(http.StatusOK, fmt.Sprintf(`{"Authenticated":"True","UserToken":"token-%d"}`, f.logins)), nil
+	}
+
+	err := req.ParseMultipartForm(1 << 20)
+	if err != nil {
+		return nil, err
+	}
+	if req.MultipartForm.Value["token"][0] != fmt.Sprintf("token-%d", f.logins) {
+		return f.response(http.StatusUnauthorized, ""), nil
+	}
+	return f.response(http.StatusOK, `[]`), nil
+}
+
+func (f *fakePCSData) response(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewBufferString(body))}
+}
+
+func TestSessionSharedRefresh(t *testing.T) {
+	fake := &fakePCSData{}
+	session := NewSession(BusDVTestURL, StaticCredentials{Username: "user", Password: "pass"}, "test", fake)
+	bus := NewBusClientWithSession(BusTestURL, session)
+	busDV := NewBusDV2ClientWithSession(BusDVTestURL, session)
+
+	_, err := bus.GetGTFS()
+	assert.NoError(t, err)
+	_, err = busDV.GetRouteTrips("PABT", "1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.logins)
+
+	// token expires: the next login will issue token-3, token-1 is rejected
+	fake.mu.Lock()
+	fake.logins++
+	fake.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := busDV.GetRouteTrips("PABT", "1")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 3, fake.logins)
+}
+
+func TestCredentialsProviders(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "credentials.json")
+	assert.NoError(t, os.WriteFile(file, []byte(`{"username":"user","password":"pass"}`), 0o600))
+	c, err := FileCredentials(file).Credentials()
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{Username: "user", Password: "pass"}, c)
+
+	t.Setenv("TEST_NJT_USERNAME", "envuser")
+	t.Setenv("TEST_NJT_PASSWORD", "envpass")
+	c, err = EnvCredentials("TEST_NJT_USERNAME", "TEST_NJT_PASSWORD").Credentials()
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{Username: "envuser", Password: "envpass"}, c)
+
+	_, err = EnvCredentials("TEST_NJT_MISSING", "TEST_NJT_PASSWORD").Credentials()
+	assert.Error(t, err)
+}
+
+func TestSessionLogger(t *testing.T) {
+	fake := &fakePCSData{}
+	session := NewSession(BusDVTestURL, StaticCredentials{Username: "user", Password: "pass"}, "test", fake)
+	busDV := NewBusDV2ClientWithSession(BusDVTestURL, session)
+
+	// silent by default
+	_, err := busDV.GetRouteTrips("PABT", "1")
+	assert.NoError(t, err)
+
+	logs := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	session.SetLogger(logger)
+	busDV.SetLogger(logger)
+
+	fake.mu.Lock()
+	fake.logins++
+	fake.mu.Unlock()
+	_, err = busDV.GetRouteTrips("PABT", "1")
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Contains(t, lines[0], `msg="njtransit login" endpoint=authenticateUser status=200`)
+		assert.Contains(t, lines[1], `msg="njtransit request" endpoint=getRouteTrips status=200`)
+		assert.Contains(t, lines[1], "bytes=2 retries=1")
+	}
+	assert.NotContains(t, logs.String(), "pass")
+}
+
+func TestMiddleware(t *testing.T) {
+	fake := &fakePCSData{}
+	session := NewSession(BusDVTestURL, StaticCredentials{Username: "user", Password: "pass"}, "test", fake)
+	busDV := NewBusDV2ClientWithSession(BusDVTestURL, session)
+
+	var calls []Call
+	busDV.Use(func(next Handler) Handler {
+		return func(call Call) (*Response, error) {
+			calls = append(calls, call)
+			return next(call)
+		}
+	})
+
+	_, err := busDV.GetRouteTrips("PABT", "1")
+	assert.NoError(t, err)
+	if assert.Len(t, calls, 2) {
+		assert.Equal(t, Call{Client: "BusDV2Client", Endpoint: "authenticateUser"}, calls[0])
+		assert.Equal(t, "BusDV2Client", calls[1].Client)
+		assert.Equal(t, "getRouteTrips", calls[1].Endpoint)
+		assert.Equal(t, "location=PABT&route=1", calls[1].Params.Encode())
+		assert.Equal(t, 0, calls[1].Retries)
+	}
+
+	// rejected token: request, login and retry all go through middleware
+	fake.mu.Lock()
+	fake.logins++
+	fake.mu.Unlock()
+	calls = nil
+	_, err = busDV.GetRouteTrips("PABT", "1")
+	assert.NoError(t, err)
+	if assert.Len(t, calls, 3) {
+		assert.Equal(t, "getRouteTrips", calls[0].Endpoint)
+		assert.Equal(t, 0, calls[0].Retries)
+		assert.Equal(t, "authenticateUser", calls[1].Endpoint)
+		assert.Equal(t, "getRouteTrips", calls[2].Endpoint)
+		assert.Equal(t, 1, calls[2].Retries)
+	}
+}
@@ -0,0 +1,253 @@
+package spatial
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// EarthRadiusMeters is the mean Earth radius used for haversine distance
+const EarthRadiusMeters = 6371008.8
+
+// DefaultCellSizeDegrees is the default size of grid cell, about 1.1 km by latitude
+const DefaultCellSizeDegrees = 0.01
+
+// Item is a single point stored in the Index
+type Item struct {
+	ID   string
+	Lat  float64
+	Lon  float64
+	Data interface{} // original stop or vehicle
+}
+
+// Result is an Item returned by queries with distance to query point
+type Result struct {
+	Item
+	DistanceMeters float64
+}
+
+type cell struct {
+	lat, lon int
+}
+
+// Index is an in-memory grid index of points,
+// safe for concurrent use
+type Index struct {
+	mu       sync.RWMutex
+	cellSize float64
+	items    map[string]Item
+	cells    map[cell]map[string]struct{}
+}
+
+// NewIndex creates new Index, cellSize is in degrees,
+// DefaultCellSizeDegrees is used when cellSize is not positive
+func NewIndex(cellSize float64) *Index {
+	if cellSize <= 0 {
+		cellSize = DefaultCellSizeDegrees
+	}
+	return &Index{
+		cellSize: cellSize,
+		items:    map[string]Item{},
+		cells:    map[cell]map[string]struct{}{},
+	}
+}
+
+// Len returns number of items in the Index
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.items)
+}
+
+// Get returns item by ID
+func (idx *Index) Get(id string) (Item, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	item, ok := idx.items[id]
+	return item, ok
+}
+
+// Upsert adds items to the Index or moves existing items with the same ID
+func (idx *Index) Upsert(items ...Item) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, item := range items {
+		idx.upsert(item)
+	}
+}
+
+// Remove removes items by ID
+func (idx *Index) Remove(ids ...string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, id := range ids {
+		idx.remove(id)
+	}
+}
+
+// Sync updates the Index to contain exactly given items:
+// new items are added, existing ones are moved, missing ones are removed.
+// Useful to refresh vehicles index with the latest positions.
+func (idx *Index) Sync(items []Item) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	seen := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		seen[item.ID] = struct{}{}
+		idx.upsert(item)
+	}
+
+	for id := range idx.items {
+		if _, ok := seen[id]; !ok {
+			idx.remove(id)
+		}
+	}
+}
+
+// Within returns items within radius (in meters) from the point, ordered by distance
+func (idx *Index) Within(lat, lon, radiusMeters float64) []Result {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	results := idx.within(lat, lon, radiusMeters)
+	sortResults(results)
+	return results
+}
+
+// Nearest returns up to k items closest to the point, ordered by distance
+func (idx *Index) Nearest(lat, lon float64, k int) []Result {
+	if k <= 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var results []Result
+	if len(idx.items) <= k {
+		results = make([]Result, 0, len(idx.items))
+		for _, item := range idx.items {
+			results = append(results, Result{Item: item, DistanceMeters: Distance(lat, lon, item.Lat, item.Lon)})
+		}
+	} else {
+		// grow search radius until it has at least k items:
+		// k nearest items are guaranteed to be among them
+		radius := idx.cellSize * math.Pi / 180 * EarthRadiusMeters
+		for {
+			results = idx.within(lat, lon, radius)
+			if len(results) >= k || radius > math.Pi*EarthRadiusMeters {
+				break
+			}
+			radius *= 2
+		}
+	}
+
+	sortResults(results)
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func (idx *Index) upsert(item Item) {
+	if current, ok := idx.items[item.ID]; ok {
+		if current.Lat == item.Lat && current.Lon == item.Lon {
+			idx.items[item.ID] = item
+			return
+		}
+		idx.remove(item.ID)
+	}
+
+	idx.items[item.ID] = item
+	c := idx.cellOf(item.Lat, item.Lon)
+	ids, ok := idx.cells[c]
+	if !ok {
+		ids = map[string]struct{}{}
+		idx.cells[c] = ids
+	}
+	ids[item.ID] = struct{}{}
+}
+
+func (idx *Index) remove(id string) {
+	item, ok := idx.items[id]
+	if !ok {
+		return
+	}
+	delete(idx.items, id)
+
+	c := idx.cellOf(item.Lat, item.Lon)
+	delete(idx.cells[c], id)
+	if len(idx.cells[c]) == 0 {
+		delete(idx.cells, c)
+	}
+}
+
+func (idx *Index) within(lat, lon, radiusMeters float64) []Result {
+	if radiusMeters < 0 {
+		return nil
+	}
+
+	dLat := radiusMeters / EarthRadiusMeters * 180 / math.Pi
+	cosLat := math.Cos(lat * math.Pi / 180)
+	dLon := 180.0
+	if cosLat > 1e-6 {
+		dLon = math.Min(dLon, dLat/cosLat)
+	}
+
+	minCell := idx.cellOf(lat-dLat, lon-dLon)
+	maxCell := idx.cellOf(lat+dLat, lon+dLon)
+
+	var results []Result
+	if (maxCell.lat-minCell.lat+1)*(maxCell.lon-minCell.lon+1) > len(idx.cells) {
+		// search area covers more cells than there are non-empty ones
+		for _, item := range idx.items {
+			results = appendWithin(results, item, lat, lon, radiusMeters)
+		}
+		return results
+	}
+
+	for cLat := minCell.lat; cLat <= maxCell.lat; cLat++ {
+		for cLon := minCell.lon; cLon <= maxCell.lon; cLon++ {
+			for id := range idx.cells[cell{lat: cLat, lon: cLon}] {
+				results = appendWithin(results, idx.items[id], lat, lon, radiusMeters)
+			}
+		}
+	}
+	return results
+}
+
+func (idx *Index) cellOf(lat, lon float64) cell {
+	return cell{
+		lat: int(math.Floor(lat / idx.cellSize)),
+		lon: int(math.Floor(lon / idx.cellSize)),
+	}
+}
+
+func appendWithin(results []Result, item Item, lat, lon, radiusMeters float64) []Result {
+	d := Distance(lat, lon, item.Lat, item.Lon)
+	if d > radiusMeters {
+		return results
+	}
+	return append(results, Result{Item: item, DistanceMeters: d})
+}
+
+func sortResults(results []Result) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].DistanceMeters == results[j].DistanceMeters {
+			return results[i].ID < results[j].ID
+		}
+		return results[i].DistanceMeters < results[j].DistanceMeters
+	})
+}
+
+// Distance returns haversine distance in meters between two points
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const rad = math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
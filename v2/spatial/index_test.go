@@ -0,0 +1,65 @@
+package spatial
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	// Newark Penn Station to New York Penn Station
+	d := Distance(40.734700, -74.164400, 40.750568, -73.993519)
+	assert.InDelta(t, 14500, d, 200)
+	assert.Equal(t, 0.0, Distance(40.7, -74.1, 40.7, -74.1))
+}
+
+func TestIndexQueries(t *testing.T) {
+	idx := NewIndex(0)
+	idx.Upsert(
+		Item{ID: "newark", Lat: 40.734700, Lon: -74.164400},
+		Item{ID: "harrison", Lat: 40.739444, Lon: -74.155833},
+		Item{ID: "secaucus", Lat: 40.761188, Lon: -74.075821},
+		Item{ID: "nypenn", Lat: 40.750568, Lon: -73.993519},
+	)
+
+	results := idx.Within(40.734700, -74.164400, 1000)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "newark", results[0].ID)
+		assert.Equal(t, "harrison", results[1].ID)
+	}
+
+	results = idx.Nearest(40.750568, -73.993519, 2)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "nypenn", results[0].ID)
+		assert.Equal(t, "secaucus", results[1].ID)
+	}
+
+	assert.Len(t, idx.Nearest(0, 0, 10), 4)
+}
+
+func TestIndexIncrementalUpdates(t *testing.T) {
+	idx := NewIndex(0)
+	idx.Sync([]Item{
+		{ID: "bus1", Lat: 40.7347, Lon: -74.1644},
+		{ID: "bus2", Lat: 40.7506, Lon: -73.9935},
+	})
+	assert.Equal(t, 2, idx.Len())
+
+	// bus1 moves to New York, bus2 disappears, bus3 appears
+	idx.Sync([]Item{
+		{ID: "bus1", Lat: 40.7510, Lon: -73.9940},
+		{ID: "bus3", Lat: 40.7347, Lon: -74.1644},
+	})
+	assert.Equal(t, 2, idx.Len())
+
+	_, ok := idx.Get("bus2")
+	assert.False(t, ok)
+
+	results := idx.Within(40.7506, -73.9935, 500)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "bus1", results[0].ID)
+	}
+
+	idx.Remove("bus1")
+	assert.Empty(t, idx.Within(40.7506, -73.9935, 500))
+}
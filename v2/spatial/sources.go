@@ -0,0 +1,115 @@
+package spatial
+
+import (
+	"strconv"
+	"strings"
+
+	njtv1 "github.com/errornil/njtransit"
+	njt "github.com/errornil/njtransit/v2"
+	"github.com/errornil/njtransit/v2/gtfsstatic"
+	gtfs "github.com/errornil/transit_realtime"
+)
+
+// FromStops converts GTFS static stops into Items keyed by stop_id,
+// Item.Data holds *gtfsstatic.Stop
+func FromStops(stops []gtfsstatic.Stop) []Item {
+	items := make([]Item, 0, len(stops))
+	for i := range stops {
+		items = append(items, Item{
+			ID:   stops[i].ID,
+			Lat:  stops[i].Lat,
+			Lon:  stops[i].Lon,
+			Data: &stops[i],
+		})
+	}
+	return items
+}
+
+// FromVehiclePositions converts GTFS-RT feed (BusClient.GetVehiclePositions) into Items
+// keyed by vehicle ID (entity ID if vehicle ID is missing),
+// Item.Data holds *gtfs.VehiclePosition. Entities without position are skipped.
+func FromVehiclePositions(feed *gtfs.FeedMessage) []Item {
+	var items []Item
+	for _, entity := range feed.GetEntity() {
+		vehicle := entity.GetVehicle()
+		if vehicle == nil || vehicle.GetPosition() == nil || entity.GetIsDeleted() {
+			continue
+		}
+
+		id := vehicle.GetVehicle().GetId()
+		if id == "" {
+			id = entity.GetId()
+		}
+
+		items = append(items, Item{
+			ID:   id,
+			Lat:  float64(vehicle.GetPosition().GetLatitude()),
+			Lon:  float64(vehicle.GetPosition().GetLongitude()),
+			Data: vehicle,
+		})
+	}
+	return items
+}
+
+// FromBusVehicleData converts BusDataClient.GetBusVehicleData response into Items
+// keyed by VehicleID, Item.Data holds njtv1.BusVehicleDataRow.
+// Rows with invalid coordinates are skipped.
+func FromBusVehicleData(resp *njtv1.GetBusVehicleDataResponse) []Item {
+	if resp == nil {
+		return nil
+	}
+
+	var items []Item
+	for _, row := range resp.Rows {
+		lat, lon, ok := parseLatLon(row.Latitude, row.Longitude)
+		if !ok {
+			continue
+		}
+		items = append(items, Item{
+			ID:   row.VehicleID,
+			Lat:  lat,
+			Lon:  lon,
+			Data: row,
+		})
+	}
+	return items
+}
+
+// FromVehicleLocations converts BusDV2Client.GetVehicleLocations response into Items
+// keyed by VehicleID, Item.Data holds njt.VehicleLocation.
+// Locations with invalid coordinates are skipped.
+func FromVehicleLocations(locations *njt.GetVehicleLocations) []Item {
+	if locations == nil {
+		return nil
+	}
+
+	var items []Item
+	for _, location := range *locations {
+		lat, lon, ok := parseLatLon(location.VehicleLat, location.VehicleLong)
+		if !ok {
+			continue
+		}
+		items = append(items, Item{
+			ID:   location.VehicleID,
+			Lat:  lat,
+			Lon:  lon,
+			Data: location,
+		})
+	}
+	return items
+}
+
+func parseLatLon(latValue, lonValue string) (float64, float64, bool) {
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latValue), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonValue), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if lat == 0 && lon == 0 || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
@@ -0,0 +1,144 @@
+package njtransit
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gtfs "github.com/errornil/transit_realtime"
+)
+
+// scheduledDepartureLayouts are formats of VehicleScheduledDeparture and DVTrip.SchedDepTime
+var scheduledDepartureLayouts = []string{
+	"1/2/2006 3:04:05 PM",     // 6/22/2023 12:40:00 AM
+	"2006-01-02T15:04:05",     // 2023-06-22T00:40:00
+	"2006-01-02 15:04:05",     // 2023-06-22 00:40:00
+	"02-Jan-2006 03:04:05 PM", // 22-Jun-2023 12:40:00 AM
+}
+
+// Vehicle is a normalized VehicleLocation with parsed fields
+type Vehicle struct {
+	ID                 string
+	Route              string
+	Destination        string
+	InternalTripNumber string
+	Lat                float64
+	Lon                float64
+	DistanceMiles      float64   // distance from the requested point
+	ScheduledDeparture time.Time // zero if not provided
+	Occupancy          gtfs.VehiclePosition_OccupancyStatus
+}
+
+// Normalize parses VehicleLocation fields, location is used for scheduled departure,
+// America/New_York is used if nil
+func (l VehicleLocation) Normalize(location *time.Location) (Vehicle, error) {
+	v := Vehicle{
+		ID:                 strings.TrimSpace(l.VehicleID),
+		Route:              strings.TrimSpace(l.VehicleRoute),
+		Destination:        strings.TrimSpace(l.VehicleDestination),
+		InternalTripNumber: strings.TrimSpace(l.VehicleInternalTripNumber),
+		Occupancy:          ParsePassengerLoad(l.VehiclePassengerLoad),
+	}
+
+	var err error
+	v.Lat, err = strconv.ParseFloat(strings.TrimSpace(l.VehicleLat), 64)
+	if err != nil || v.Lat < -90 || v.Lat > 90 {
+		return Vehicle{}, fmt.Errorf("invalid VehicleLat: %q", l.VehicleLat)
+	}
+	v.Lon, err = strconv.ParseFloat(strings.TrimSpace(l.VehicleLong), 64)
+	if err != nil || v.Lon < -180 || v.Lon > 180 {
+		return Vehicle{}, fmt.Errorf("invalid VehicleLong: %q", l.VehicleLong)
+	}
+
+	if distance := strings.TrimSpace(l.VehicleDistanceMiles); distance != "" {
+		v.DistanceMiles, err = strconv.ParseFloat(distance, 64)
+		if err != nil {
+			return Vehicle{}, fmt.Errorf("invalid VehicleDistanceMiles: %q", l.VehicleDistanceMiles)
+		}
+	}
+
+	v.ScheduledDeparture, err = ParseScheduledDeparture(l.VehicleScheduledDeparture, location)
+	if err != nil {
+		return Vehicle{}, err
+	}
+
+	return v, nil
+}
+
+// Normalize returns vehicles sorted by distance, locations that can't be parsed are skipped
+func (l GetVehicleLocations) Normalize(location *time.Location) []Vehicle {
+	vehicles := make([]Vehicle, 0, len(l))
+	for _, vl := range l {
+		v, err := vl.Normalize(location)
+		if err != nil {
+			continue
+		}
+		vehicles = append(vehicles, v)
+	}
+
+	sort.SliceStable(vehicles, func(i, j int) bool {
+		return vehicles[i].DistanceMiles < vehicles[j].DistanceMiles
+	})
+	return vehicles
+}
+
+// ParseScheduledDeparture parses VehicleScheduledDeparture or DVTrip.SchedDepTime,
+// returns zero time for empty value; America/New_York is used if location is nil
+func ParseScheduledDeparture(value string, location *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if location == nil {
+		location = TimeZone()
+	}
+
+	for _, layout := range scheduledDepartureLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid scheduled departure: %q", value)
+}
+
+// ParsePassengerLoad maps VehiclePassengerLoad and DVTrip.PassLoad to GTFS-RT occupancy status,
+// both named levels (LIGHT, MEDIUM, HEAVY, ...) and percentages are supported;
+// unknown values are NO_DATA_AVAILABLE
+func ParsePassengerLoad(load string) gtfs.VehiclePosition_OccupancyStatus {
+	load = strings.ToUpper(strings.TrimSpace(load))
+
+	if percent, err := strconv.ParseFloat(strings.TrimSuffix(load, "%"), 64); err == nil {
+		switch {
+		case percent < 0:
+			return gtfs.VehiclePosition_NO_DATA_AVAILABLE
+		case percent < 10:
+			return gtfs.VehiclePosition_EMPTY
+		case percent < 50:
+			return gtfs.VehiclePosition_MANY_SEATS_AVAILABLE
+		case percent < 80:
+			return gtfs.VehiclePosition_FEW_SEATS_AVAILABLE
+		case percent < 100:
+			return gtfs.VehiclePosition_STANDING_ROOM_ONLY
+		default:
+			return gtfs.VehiclePosition_FULL
+		}
+	}
+
+	switch load {
+	case "EMPTY":
+		return gtfs.VehiclePosition_EMPTY
+	case "LIGHT", "LOW", "MANY SEATS AVAILABLE":
+		return gtfs.VehiclePosition_MANY_SEATS_AVAILABLE
+	case "MEDIUM", "MODERATE", "FEW SEATS AVAILABLE":
+		return gtfs.VehiclePosition_FEW_SEATS_AVAILABLE
+	case "HEAVY", "HIGH", "STANDING ROOM ONLY":
+		return gtfs.VehiclePosition_STANDING_ROOM_ONLY
+	case "CRUSHED", "CRUSHED STANDING ROOM ONLY":
+		return gtfs.VehiclePosition_CRUSHED_STANDING_ROOM_ONLY
+	case "FULL":
+		return gtfs.VehiclePosition_FULL
+	}
+	return gtfs.VehiclePosition_NO_DATA_AVAILABLE
+}
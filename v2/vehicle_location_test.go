@@ -0,0 +1,47 @@
+package njtransit
+
+import (
+	"testing"
+	"time"
+
+	gtfs "github.com/errornil/transit_realtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetVehicleLocationsNormalize(t *testing.T) {
+	locations := GetVehicleLocations{
+		{VehicleID: "6012", VehicleLat: "40.74", VehicleLong: "-74.17", VehicleDistanceMiles: "1.2", VehiclePassengerLoad: "HEAVY", VehicleRoute: "13", VehicleScheduledDeparture: "6/22/2023 1:05:00 AM"},
+		{VehicleID: "8243", VehicleLat: "40.73", VehicleLong: "-74.16", VehicleDistanceMiles: "0.4", VehiclePassengerLoad: "LIGHT", VehicleRoute: "1"},
+		{VehicleID: "bad", VehicleLat: "", VehicleLong: "-74.16"},
+	}
+
+	vehicles := locations.Normalize(time.UTC)
+	if assert.Len(t, vehicles, 2) {
+		assert.Equal(t, "8243", vehicles[0].ID)
+		assert.Equal(t, gtfs.VehiclePosition_MANY_SEATS_AVAILABLE, vehicles[0].Occupancy)
+		assert.True(t, vehicles[0].ScheduledDeparture.IsZero())
+
+		assert.Equal(t, Vehicle{
+			ID:                 "6012",
+			Route:              "13",
+			Lat:                40.74,
+			Lon:                -74.17,
+			DistanceMiles:      1.2,
+			ScheduledDeparture: time.Date(2023, 6, 22, 1, 5, 0, 0, time.UTC),
+			Occupancy:          gtfs.VehiclePosition_STANDING_ROOM_ONLY,
+		}, vehicles[1])
+	}
+}
+
+func TestParsePassengerLoad(t *testing.T) {
+	assert.Equal(t, gtfs.VehiclePosition_FEW_SEATS_AVAILABLE, ParsePassengerLoad(" medium "))
+	assert.Equal(t, gtfs.VehiclePosition_FULL, ParsePassengerLoad("105%"))
+	assert.Equal(t, gtfs.VehiclePosition_NO_DATA_AVAILABLE, ParsePassengerLoad(""))
+}
+
+func TestParseScheduledDepartureDefaultLocation(t *testing.T) {
+	departure, err := ParseScheduledDeparture("6/22/2023 1:05:00 AM", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "America/New_York", departure.Location().String())
+	assert.Equal(t, time.Date(2023, 6, 22, 5, 5, 0, 0, time.UTC), departure.UTC())
+}